@@ -0,0 +1,131 @@
+// Package chart renders temps series of cities as charts
+package chart
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/ekougs/weather-station/util"
+)
+
+// Point is a temp at a time
+type Point struct {
+	Time  time.Time
+	Value int
+}
+
+// Series is the temps of a city in time order
+type Series struct {
+	Name   string
+	Points []Point
+}
+
+// FromCityTemps returns the series of temps named name
+func FromCityTemps(name string, cityTemps util.CityTemps) Series {
+	points := make([]Point, 0, len(cityTemps.Temps))
+	for _, temp := range cityTemps.Temps {
+		points = append(points, Point{time.Time(temp.Time), temp.Temp})
+	}
+	return Series{name, points}
+}
+
+// Stats returns the points with the lowest and highest temps, the earliest
+// ones if several, and the average temp
+func (series Series) Stats() (min, max Point, average float64) {
+	if len(series.Points) == 0 {
+		return
+	}
+	min, max = series.Points[0], series.Points[0]
+	sum := 0
+	for _, point := range series.Points {
+		if point.Value < min.Value {
+			min = point
+		}
+		if point.Value > max.Value {
+			max = point
+		}
+		sum += point.Value
+	}
+	return min, max, float64(sum) / float64(len(series.Points))
+}
+
+// bounds returns the lowest and highest temps of all series, different so
+// that they can be used as a scale
+func bounds(allSeries []Series) (low, high int, err error) {
+	found := false
+	for _, series := range allSeries {
+		if len(series.Points) == 0 {
+			continue
+		}
+		min, max, _ := series.Stats()
+		if !found || min.Value < low {
+			low = min.Value
+		}
+		if !found || max.Value > high {
+			high = max.Value
+		}
+		found = true
+	}
+	if !found {
+		return 0, 0, fmt.Errorf("No temps to chart")
+	}
+	if low == high {
+		low, high = low-1, high+1
+	}
+	return low, high, nil
+}
+
+// resample returns count values of series evenly spread over its points,
+// averaging points sharing a value and interpolating between distant ones
+func resample(series Series, count int) []float64 {
+	values := make([]float64, count)
+	points := series.Points
+	if len(points) == 1 || count == 1 {
+		for index := range values {
+			values[index] = float64(points[0].Value)
+		}
+		return values
+	}
+	if len(points) >= count {
+		for index := range values {
+			start, end := index*len(points)/count, (index+1)*len(points)/count
+			sum := 0
+			for _, point := range points[start:end] {
+				sum += point.Value
+			}
+			values[index] = float64(sum) / float64(end-start)
+		}
+		return values
+	}
+	for index := range values {
+		position := float64(index) * float64(len(points)-1) / float64(count-1)
+		before := int(position)
+		if before == len(points)-1 {
+			values[index] = float64(points[before].Value)
+			continue
+		}
+		ratio := position - float64(before)
+		values[index] = float64(points[before].Value)*(1-ratio) + float64(points[before+1].Value)*ratio
+	}
+	return values
+}
+
+// column returns the column of the point at index among count points spread
+// over width columns
+func column(index, count, width int) int {
+	if count <= width {
+		if count == 1 {
+			return 0
+		}
+		return index * (width - 1) / (count - 1)
+	}
+	return index * width / count
+}
+
+// timeLabel formats times as dates unless series last less than two days
+func timeLabel(first, last time.Time) func(time.Time) string {
+	if last.Sub(first) < 48*time.Hour {
+		return func(instant time.Time) string { return instant.Format("01-02 15:04") }
+	}
+	return func(instant time.Time) string { return instant.Format("2006-01-02") }
+}
@@ -0,0 +1,198 @@
+package chart
+
+import (
+	"fmt"
+	"image/color"
+	"strconv"
+	"time"
+)
+
+// Bounds of image sizes in pixels
+const (
+	MinImageWidth  = 200
+	MaxImageWidth  = 2000
+	MinImageHeight = 100
+	MaxImageHeight = 1200
+)
+
+// palette colors series in order
+var palette = []color.RGBA{
+	{0x1f, 0x77, 0xb4, 0xff},
+	{0xd6, 0x27, 0x28, 0xff},
+	{0x2c, 0xa0, 0x2c, 0xff},
+	{0xff, 0x7f, 0x0e, 0xff},
+	{0x94, 0x67, 0xbd, 0xff},
+	{0x8c, 0x56, 0x4b, 0xff},
+}
+
+var (
+	backgroundColor = color.RGBA{0xff, 0xff, 0xff, 0xff}
+	axisColor       = color.RGBA{0x33, 0x33, 0x33, 0xff}
+	gridColor       = color.RGBA{0xdd, 0xdd, 0xdd, 0xff}
+)
+
+// charWidth is the approximate width of characters of labels
+const charWidth = 8
+
+// position is a point of an image in pixels from its top left corner
+type position struct {
+	x, y float64
+}
+
+// stroke tells how lines are drawn
+type stroke struct {
+	color  color.RGBA
+	width  int
+	dashed bool
+}
+
+// anchor tells which part of a text is at its position
+type anchor int
+
+const (
+	anchorStart anchor = iota
+	anchorMiddle
+	anchorEnd
+)
+
+// canvas is an image format on which charts are drawn
+// Texts are positioned on their baseline
+type canvas interface {
+	line(from, to position, stroke stroke)
+	text(at position, text string, anchor anchor, color color.RGBA)
+	dot(at position, color color.RGBA)
+}
+
+// checkImageSize returns an error if the size is out of bounds
+func checkImageSize(width, height int) error {
+	if width < MinImageWidth || width > MaxImageWidth || height < MinImageHeight || height > MaxImageHeight {
+		return fmt.Errorf("Image size %dx%d should be between %dx%d and %dx%d", width, height, MinImageWidth, MinImageHeight, MaxImageWidth, MaxImageHeight)
+	}
+	return nil
+}
+
+// drawChart draws a line chart of series with a title, its time axis in the time
+// zone of the first series points. A single series gets lines at its lowest
+// and highest temps and its average, several ones get their average line
+// and their lowest and highest temps marked
+func drawChart(canvas canvas, allSeries []Series, width, height int, title string) error {
+	low, high, err := bounds(allSeries)
+	if err != nil {
+		return err
+	}
+	step := tickStep(high-low, 5)
+	low, high = floorTo(low, step), ceilTo(high, step)
+	if low == high {
+		high += step
+	}
+	first, last := timeRange(allSeries)
+	span := last.Time.Sub(first.Time)
+
+	left, right, top, bottom := 50.0, float64(width)-60, 48.0, float64(height)-30
+	x := func(instant time.Time) float64 {
+		if span == 0 {
+			return (left + right) / 2
+		}
+		return left + float64(instant.Sub(first.Time))/float64(span)*(right-left)
+	}
+	y := func(value float64) float64 {
+		return bottom - (value-float64(low))/float64(high-low)*(bottom-top)
+	}
+
+	canvas.text(position{float64(width) / 2, 18}, title, anchorMiddle, axisColor)
+	for value := low; value <= high; value += step {
+		canvas.line(position{left, y(float64(value))}, position{right, y(float64(value))}, stroke{gridColor, 1, false})
+		canvas.text(position{left - 6, y(float64(value)) + 4}, strconv.Itoa(value), anchorEnd, axisColor)
+	}
+
+	format := timeLabel(first.Time, last.Time)
+	ticks := int((right - left) / 120)
+	if ticks < 1 || span == 0 {
+		ticks = 1
+	}
+	for tick := 0; tick <= ticks && (tick == 0 || span > 0); tick++ {
+		instant := first.Time.Add(time.Duration(float64(span) * float64(tick) / float64(ticks))).In(first.Time.Location())
+		tickAnchor := anchorMiddle
+		switch {
+		case span == 0:
+		case tick == 0:
+			tickAnchor = anchorStart
+		case tick == ticks:
+			tickAnchor = anchorEnd
+		}
+		canvas.line(position{x(instant), bottom}, position{x(instant), bottom + 4}, stroke{axisColor, 1, false})
+		canvas.text(position{x(instant), bottom + 18}, format(instant), tickAnchor, axisColor)
+	}
+	canvas.line(position{left, top}, position{left, bottom}, stroke{axisColor, 1, false})
+	canvas.line(position{left, bottom}, position{right, bottom}, stroke{axisColor, 1, false})
+
+	legendX := left
+	for index, series := range allSeries {
+		if len(series.Points) == 0 {
+			continue
+		}
+		seriesColor := palette[index%len(palette)]
+		min, max, average := series.Stats()
+		formattedAverage := strconv.FormatFloat(average, 'f', 1, 64)
+
+		canvas.line(position{left, y(average)}, position{right, y(average)}, stroke{seriesColor, 1, true})
+		if len(allSeries) == 1 {
+			for _, line := range []struct {
+				value float64
+				label string
+			}{{float64(max.Value), "max " + strconv.Itoa(max.Value)}, {float64(min.Value), "min " + strconv.Itoa(min.Value)}} {
+				canvas.line(position{left, y(line.value)}, position{right, y(line.value)}, stroke{seriesColor, 1, true})
+				canvas.text(position{right + 4, y(line.value) + 4}, line.label, anchorStart, seriesColor)
+			}
+			canvas.text(position{right + 4, y(average) + 4}, "avg "+formattedAverage, anchorStart, seriesColor)
+		}
+
+		previous := position{x(series.Points[0].Time), y(float64(series.Points[0].Value))}
+		for _, point := range series.Points[1:] {
+			current := position{x(point.Time), y(float64(point.Value))}
+			canvas.line(previous, current, stroke{seriesColor, 2, false})
+			previous = current
+		}
+		if len(series.Points) == 1 {
+			canvas.dot(previous, seriesColor)
+		}
+		if len(allSeries) > 1 {
+			maxPosition, minPosition := position{x(max.Time), y(float64(max.Value))}, position{x(min.Time), y(float64(min.Value))}
+			canvas.dot(maxPosition, seriesColor)
+			canvas.text(position{maxPosition.x, maxPosition.y - 6}, strconv.Itoa(max.Value), anchorMiddle, seriesColor)
+			canvas.dot(minPosition, seriesColor)
+			canvas.text(position{minPosition.x, minPosition.y + 16}, strconv.Itoa(min.Value), anchorMiddle, seriesColor)
+		}
+
+		legend := series.Name + " avg " + formattedAverage
+		canvas.text(position{legendX, 36}, legend, anchorStart, seriesColor)
+		legendX += float64(len(legend)*charWidth + 2*charWidth)
+	}
+	return nil
+}
+
+// tickStep returns the smallest step among 1, 2, 5, 10, 20, 50... splitting
+// span in at most count parts
+func tickStep(span, count int) int {
+	for magnitude := 1; ; magnitude *= 10 {
+		for _, factor := range []int{1, 2, 5} {
+			if step := factor * magnitude; span <= step*count {
+				return step
+			}
+		}
+	}
+}
+
+func floorTo(value, step int) int {
+	if value >= 0 {
+		return value / step * step
+	}
+	return -ceilTo(-value, step)
+}
+
+func ceilTo(value, step int) int {
+	if value >= 0 {
+		return (value + step - 1) / step * step
+	}
+	return -floorTo(-value, step)
+}
@@ -0,0 +1,53 @@
+package chart
+
+import (
+	"bytes"
+	"image/png"
+	"strings"
+	"testing"
+)
+
+func TestSVGDrawsTitleLinesAndStats(t *testing.T) {
+	var buffer bytes.Buffer
+	if err := SVG(&buffer, []Series{testSeries("PAR", 3, 12, 6, 7)}, 800, 400, "Temps of PAR & co"); err != nil {
+		t.Fatal(err)
+	}
+	svg := buffer.String()
+	for _, expected := range []string{`<svg xmlns="http://www.w3.org/2000/svg" width="800" height="400"`, "Temps of PAR &amp; co",
+		">max 12<", ">min 3<", ">avg 7.0<", ">2020-02-01<", ">2020-02-04<", `stroke-width="2"`} {
+		if !strings.Contains(svg, expected) {
+			t.Errorf("Should contain '%s' but was\n%s", expected, svg)
+		}
+	}
+}
+
+func TestPNGHasRequestedSize(t *testing.T) {
+	var buffer bytes.Buffer
+	if err := PNG(&buffer, []Series{testSeries("PAR", 3, 4), testSeries("NYC", 1, 9)}, 300, 200, "Temps"); err != nil {
+		t.Fatal(err)
+	}
+	image, err := png.Decode(&buffer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bounds := image.Bounds(); bounds.Dx() != 300 || bounds.Dy() != 200 {
+		t.Errorf("Should be 300x200 but was %v", bounds)
+	}
+}
+
+func TestImagesFailOutOfSizeBounds(t *testing.T) {
+	if err := SVG(&bytes.Buffer{}, []Series{testSeries("PAR", 3)}, MaxImageWidth+1, 400, ""); err == nil {
+		t.Error("Should fail with a too wide image")
+	}
+	if err := PNG(&bytes.Buffer{}, []Series{testSeries("PAR", 3)}, 800, MinImageHeight-1, ""); err == nil {
+		t.Error("Should fail with a too low image")
+	}
+}
+
+func TestTickStepSplitsSpanInRoundSteps(t *testing.T) {
+	for span, expected := range map[int]int{2: 1, 9: 2, 20: 5, 26: 10, 180: 50} {
+		if step := tickStep(span, 5); step != expected {
+			t.Errorf("Should have step %d for %d but was %d", expected, span, step)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package chart
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io"
+	"math"
+	"strings"
+)
+
+// PNGContentType is the media type of PNG images
+const PNGContentType = "image/png"
+
+// glyphScale is the size in pixels of every dot of glyphs
+const glyphScale = 2
+
+// glyphs are 3 dots wide and 5 dots high, lower case letters being drawn
+// like upper case ones. Unknown characters are left blank
+var glyphs = map[rune]string{
+	'0': "111 101 101 101 111", '1': "010 110 010 010 111", '2': "111 001 111 100 111",
+	'3': "111 001 111 001 111", '4': "101 101 111 001 001", '5': "111 100 111 001 111",
+	'6': "111 100 111 101 111", '7': "111 001 001 001 001", '8': "111 101 111 101 111",
+	'9': "111 101 111 001 111", 'A': "010 101 111 101 101", 'B': "110 101 110 101 110",
+	'C': "011 100 100 100 011", 'D': "110 101 101 101 110", 'E': "111 100 110 100 111",
+	'F': "111 100 110 100 100", 'G': "011 100 101 101 011", 'H': "101 101 111 101 101",
+	'I': "111 010 010 010 111", 'J': "001 001 001 101 010", 'K': "101 101 110 101 101",
+	'L': "100 100 100 100 111", 'M': "101 111 111 101 101", 'N': "110 101 101 101 101",
+	'O': "010 101 101 101 010", 'P': "110 101 110 100 100", 'Q': "010 101 101 110 011",
+	'R': "110 101 110 101 101", 'S': "011 100 010 001 110", 'T': "111 010 010 010 010",
+	'U': "101 101 101 101 111", 'V': "101 101 101 101 010", 'W': "101 101 111 111 101",
+	'X': "101 101 010 101 101", 'Y': "101 101 010 010 010", 'Z': "111 001 010 100 111",
+	'-': "000 000 111 000 000", ':': "000 010 000 010 000", '.': "000 000 000 000 010",
+	',': "000 000 000 010 100", '+': "000 010 111 010 000", '/': "001 001 010 100 100",
+	'(': "001 010 010 010 001", ')': "100 010 010 010 100", '°': "010 101 010 000 000",
+}
+
+// pngCanvas draws on an RGBA image without anti-aliasing
+type pngCanvas struct {
+	image *image.RGBA
+}
+
+func (canvas pngCanvas) line(from, to position, stroke stroke) {
+	steps := int(math.Max(math.Abs(to.x-from.x), math.Abs(to.y-from.y)))
+	horizontal := math.Abs(to.x-from.x) >= math.Abs(to.y-from.y)
+	for step := 0; step <= steps; step++ {
+		if stroke.dashed && step%7 >= 4 {
+			continue
+		}
+		ratio := 0.0
+		if steps > 0 {
+			ratio = float64(step) / float64(steps)
+		}
+		x := int(math.Floor(from.x + (to.x-from.x)*ratio + 0.5))
+		y := int(math.Floor(from.y + (to.y-from.y)*ratio + 0.5))
+		for offset := 0; offset < stroke.width; offset++ {
+			if horizontal {
+				canvas.image.SetRGBA(x, y+offset, stroke.color)
+			} else {
+				canvas.image.SetRGBA(x+offset, y, stroke.color)
+			}
+		}
+	}
+}
+
+func (canvas pngCanvas) text(at position, text string, anchor anchor, color color.RGBA) {
+	text = strings.ToUpper(text)
+	textWidth := float64(len([]rune(text))*(4*glyphScale) - glyphScale)
+	left := at.x
+	switch anchor {
+	case anchorMiddle:
+		left -= textWidth / 2
+	case anchorEnd:
+		left -= textWidth
+	}
+	top := int(at.y) - 5*glyphScale
+	for index, character := range []rune(text) {
+		rows := strings.Fields(glyphs[character])
+		glyphLeft := int(left) + index*4*glyphScale
+		for row, dots := range rows {
+			for column, dot := range dots {
+				if dot == '1' {
+					dotRectangle := image.Rect(glyphLeft+column*glyphScale, top+row*glyphScale, glyphLeft+(column+1)*glyphScale, top+(row+1)*glyphScale)
+					draw.Draw(canvas.image, dotRectangle, image.NewUniform(color), image.Point{}, draw.Src)
+				}
+			}
+		}
+	}
+}
+
+func (canvas pngCanvas) dot(at position, color color.RGBA) {
+	for x := -3; x <= 3; x++ {
+		for y := -3; y <= 3; y++ {
+			if x*x+y*y <= 9 {
+				canvas.image.SetRGBA(int(at.x)+x, int(at.y)+y, color)
+			}
+		}
+	}
+}
+
+// PNG writes a line chart of series as a PNG image of width by height pixels
+func PNG(writer io.Writer, allSeries []Series, width, height int, title string) error {
+	if err := checkImageSize(width, height); err != nil {
+		return err
+	}
+	canvas := pngCanvas{image.NewRGBA(image.Rect(0, 0, width, height))}
+	draw.Draw(canvas.image, canvas.image.Bounds(), image.NewUniform(backgroundColor), image.Point{}, draw.Src)
+	if err := drawChart(canvas, allSeries, width, height, title); err != nil {
+		return err
+	}
+	return png.Encode(writer, canvas.image)
+}
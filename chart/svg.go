@@ -0,0 +1,59 @@
+package chart
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"image/color"
+	"io"
+)
+
+// SVGContentType is the media type of SVG images
+const SVGContentType = "image/svg+xml"
+
+// svgCanvas writes SVG elements
+type svgCanvas struct {
+	buffer bytes.Buffer
+}
+
+var svgAnchors = []string{"start", "middle", "end"}
+
+func svgColor(rgba color.RGBA) string {
+	return fmt.Sprintf("#%02x%02x%02x", rgba.R, rgba.G, rgba.B)
+}
+
+func (canvas *svgCanvas) line(from, to position, stroke stroke) {
+	dashes := ""
+	if stroke.dashed {
+		dashes = ` stroke-dasharray="4 3"`
+	}
+	fmt.Fprintf(&canvas.buffer, `<line x1="%.1f" y1="%.1f" x2="%.1f" y2="%.1f" stroke="%s" stroke-width="%d"%s/>`+"\n",
+		from.x, from.y, to.x, to.y, svgColor(stroke.color), stroke.width, dashes)
+}
+
+func (canvas *svgCanvas) text(at position, text string, anchor anchor, color color.RGBA) {
+	fmt.Fprintf(&canvas.buffer, `<text x="%.1f" y="%.1f" fill="%s" text-anchor="%s">`, at.x, at.y, svgColor(color), svgAnchors[anchor])
+	xml.EscapeText(&canvas.buffer, []byte(text))
+	canvas.buffer.WriteString("</text>\n")
+}
+
+func (canvas *svgCanvas) dot(at position, color color.RGBA) {
+	fmt.Fprintf(&canvas.buffer, `<circle cx="%.1f" cy="%.1f" r="3" fill="%s"/>`+"\n", at.x, at.y, svgColor(color))
+}
+
+// SVG writes a line chart of series as an SVG image of width by height pixels
+func SVG(writer io.Writer, allSeries []Series, width, height int, title string) error {
+	if err := checkImageSize(width, height); err != nil {
+		return err
+	}
+	canvas := &svgCanvas{}
+	fmt.Fprintf(&canvas.buffer, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" font-family="sans-serif" font-size="12">`+"\n",
+		width, height, width, height)
+	fmt.Fprintf(&canvas.buffer, `<rect width="100%%" height="100%%" fill="%s"/>`+"\n", svgColor(backgroundColor))
+	if err := drawChart(canvas, allSeries, width, height, title); err != nil {
+		return err
+	}
+	canvas.buffer.WriteString("</svg>\n")
+	_, err := canvas.buffer.WriteTo(writer)
+	return err
+}
@@ -0,0 +1,177 @@
+package chart
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// markers draw the points of every series, the first one for the first series
+var markers = []rune("*+ox#%@")
+
+const (
+	maxMarker     = '▲'
+	minMarker     = '▼'
+	averageMarker = '┄'
+)
+
+var sparks = []rune("▁▂▃▄▅▆▇█")
+
+// Text writes a line chart of series width characters wide and height lines
+// high above its axes and legend. Series share the same axes; a single one
+// gets its lowest and highest temps and its average marked
+func Text(writer io.Writer, allSeries []Series, width, height int) error {
+	low, high, err := bounds(allSeries)
+	if err != nil {
+		return err
+	}
+	if height < 3 {
+		height = 3
+	}
+	row := func(value float64) int {
+		return int(math.Floor((float64(high)-value)/float64(high-low)*float64(height-1) + 0.5))
+	}
+
+	labels := make([]string, height)
+	labels[0], labels[height-1] = strconv.Itoa(high), strconv.Itoa(low)
+	single := len(allSeries) == 1
+	averageRow := -1
+	if single {
+		_, _, average := allSeries[0].Stats()
+		averageRow = row(average)
+		if labels[averageRow] == "" {
+			labels[averageRow] = strconv.FormatFloat(average, 'f', 0, 64)
+		}
+	}
+	labelWidth := 0
+	for _, label := range labels {
+		if len(label) > labelWidth {
+			labelWidth = len(label)
+		}
+	}
+	plotWidth := width - labelWidth - 2
+	if plotWidth < 2 {
+		plotWidth = 2
+	}
+
+	grid := make([][]rune, height)
+	for index := range grid {
+		fill := ' '
+		if index == averageRow {
+			fill = averageMarker
+		}
+		grid[index] = []rune(strings.Repeat(string(fill), plotWidth))
+	}
+	for index, series := range allSeries {
+		if len(series.Points) == 0 {
+			continue
+		}
+		marker := markers[index%len(markers)]
+		for column, value := range resample(series, plotWidth) {
+			grid[row(value)][column] = marker
+		}
+	}
+	if single && len(allSeries[0].Points) > 0 {
+		points := allSeries[0].Points
+		min, max, _ := allSeries[0].Stats()
+		for index, point := range points {
+			if point == max {
+				grid[0][column(index, len(points), plotWidth)] = maxMarker
+			}
+			if point == min {
+				grid[height-1][column(index, len(points), plotWidth)] = minMarker
+			}
+		}
+	}
+
+	for index, line := range grid {
+		axis := "│"
+		if labels[index] != "" {
+			axis = "┤"
+		}
+		fmt.Fprintf(writer, "%*s %s%s\n", labelWidth, labels[index], axis, string(line))
+	}
+	fmt.Fprintf(writer, "%*s └%s\n", labelWidth, "", strings.Repeat("─", plotWidth))
+
+	first, last := timeRange(allSeries)
+	format := timeLabel(first.Time, last.Time)
+	firstLabel, lastLabel := format(first.Time), format(last.Time)
+	gap := plotWidth - len(firstLabel) - len(lastLabel)
+	if gap < 1 {
+		gap = 1
+	}
+	fmt.Fprintf(writer, "%*s  %s%s%s\n", labelWidth, "", firstLabel, strings.Repeat(" ", gap), lastLabel)
+
+	for index, series := range allSeries {
+		if len(series.Points) == 0 {
+			continue
+		}
+		min, max, average := series.Stats()
+		marker := markers[index%len(markers)]
+		if single {
+			fmt.Fprintf(writer, "%c %s  %c max %d %s  %c min %d %s  %c average %s\n", marker, series.Name,
+				maxMarker, max.Value, format(max.Time), minMarker, min.Value, format(min.Time),
+				averageMarker, strconv.FormatFloat(average, 'f', 1, 64))
+			continue
+		}
+		fmt.Fprintf(writer, "%c %s  max %d %s  min %d %s  average %s\n", marker, series.Name,
+			max.Value, format(max.Time), min.Value, format(min.Time), strconv.FormatFloat(average, 'f', 1, 64))
+	}
+	return nil
+}
+
+// Sparklines writes a line per series with its name, a sparkline at most
+// width characters wide and its stats. Sparklines share the same scale so
+// that cities can be compared
+func Sparklines(writer io.Writer, allSeries []Series, width int) error {
+	low, high, err := bounds(allSeries)
+	if err != nil {
+		return err
+	}
+	nameWidth := 0
+	for _, series := range allSeries {
+		if len(series.Name) > nameWidth {
+			nameWidth = len(series.Name)
+		}
+	}
+	for _, series := range allSeries {
+		if len(series.Points) == 0 {
+			continue
+		}
+		min, max, average := series.Stats()
+		stats := fmt.Sprintf("min %d  max %d  average %s", min.Value, max.Value, strconv.FormatFloat(average, 'f', 1, 64))
+		sparkWidth := width - nameWidth - len(stats) - 4
+		if sparkWidth > len(series.Points) {
+			sparkWidth = len(series.Points)
+		}
+		if sparkWidth < 1 {
+			sparkWidth = 1
+		}
+		line := make([]rune, 0, sparkWidth)
+		for _, value := range resample(series, sparkWidth) {
+			level := int(math.Floor((value-float64(low))/float64(high-low)*float64(len(sparks)-1) + 0.5))
+			line = append(line, sparks[level])
+		}
+		fmt.Fprintf(writer, "%-*s  %s  %s\n", nameWidth, series.Name, string(line), stats)
+	}
+	return nil
+}
+
+// timeRange returns the earliest and latest points of all series
+func timeRange(allSeries []Series) (first, last Point) {
+	found := false
+	for _, series := range allSeries {
+		for _, point := range series.Points {
+			if !found || point.Time.Before(first.Time) {
+				first = point
+			}
+			if !found || point.Time.After(last.Time) {
+				last = point
+			}
+			found = true
+		}
+	}
+	return first, last
+}
@@ -0,0 +1,103 @@
+package chart
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testSeries(name string, values ...int) Series {
+	start := time.Date(2020, time.February, 1, 10, 0, 0, 0, time.UTC)
+	points := make([]Point, 0, len(values))
+	for index, value := range values {
+		points = append(points, Point{start.AddDate(0, 0, index), value})
+	}
+	return Series{name, points}
+}
+
+func TestStatsReturnsEarliestExtremesAndAverage(t *testing.T) {
+	series := testSeries("PAR", 5, 2, 9, 2, 9)
+	min, max, average := series.Stats()
+	if min != series.Points[1] || max != series.Points[2] {
+		t.Errorf("Should have min %v and max %v but was %v and %v", series.Points[1], series.Points[2], min, max)
+	}
+	if average != 5.4 {
+		t.Errorf("Should have average 5.4 but was %f", average)
+	}
+}
+
+func TestResampleAveragesPointsSharingAColumn(t *testing.T) {
+	values := resample(testSeries("PAR", 1, 3, 5, 7), 2)
+	if len(values) != 2 || values[0] != 2 || values[1] != 6 {
+		t.Errorf("Should have [2 6] but was %v", values)
+	}
+}
+
+func TestResampleInterpolatesDistantPoints(t *testing.T) {
+	values := resample(testSeries("PAR", 0, 10), 5)
+	expected := []float64{0, 2.5, 5, 7.5, 10}
+	for index := range expected {
+		if values[index] != expected[index] {
+			t.Fatalf("Should have %v but was %v", expected, values)
+		}
+	}
+}
+
+func TestTextMarksExtremesAndAverageOfASingleSeries(t *testing.T) {
+	var buffer bytes.Buffer
+	if err := Text(&buffer, []Series{testSeries("PAR", 3, 12, 6, 7)}, 40, 10); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimRight(buffer.String(), "\n"), "\n")
+	if len(lines) != 13 {
+		t.Fatalf("Should have 10 lines, axis, dates and legend but was\n%s", buffer.String())
+	}
+	if !strings.HasPrefix(lines[0], "12 ┤") || !strings.ContainsRune(lines[0], maxMarker) {
+		t.Errorf("Should start with the max marked but was '%s'", lines[0])
+	}
+	if !strings.HasPrefix(lines[9], " 3 ┤") || !strings.ContainsRune(lines[9], minMarker) {
+		t.Errorf("Should end with the min marked but was '%s'", lines[9])
+	}
+	if !strings.Contains(buffer.String(), string(averageMarker)) {
+		t.Errorf("Should draw the average but was\n%s", buffer.String())
+	}
+	if !strings.Contains(lines[11], "2020-02-01") || !strings.HasSuffix(lines[11], "2020-02-04") {
+		t.Errorf("Should label first and last dates but was '%s'", lines[11])
+	}
+	if lines[12] != "* PAR  ▲ max 12 2020-02-02  ▼ min 3 2020-02-01  ┄ average 7.0" {
+		t.Errorf("Should have a legend with stats but was '%s'", lines[12])
+	}
+}
+
+func TestTextOverlaysSeriesWithTheirMarkers(t *testing.T) {
+	var buffer bytes.Buffer
+	err := Text(&buffer, []Series{testSeries("PAR", 3, 4, 5), testSeries("DKR", 20, 21, 22)}, 40, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(buffer.String(), "\n")
+	if !strings.ContainsRune(lines[0], markers[1]) || !strings.ContainsRune(lines[9], markers[0]) {
+		t.Errorf("Should draw DKR above PAR but was\n%s", buffer.String())
+	}
+	if strings.ContainsRune(buffer.String(), averageMarker) {
+		t.Errorf("Should not draw averages of several series but was\n%s", buffer.String())
+	}
+}
+
+func TestTextFailsWithoutTemps(t *testing.T) {
+	if err := Text(&bytes.Buffer{}, []Series{{"PAR", nil}}, 40, 10); err == nil {
+		t.Error("Should fail without temps")
+	}
+}
+
+func TestSparklinesShareTheirScale(t *testing.T) {
+	var buffer bytes.Buffer
+	if err := Sparklines(&buffer, []Series{testSeries("PAR", 0, 5), testSeries("NYC", 5, 10)}, 80); err != nil {
+		t.Fatal(err)
+	}
+	expected := "PAR  ▁▅  min 0  max 5  average 2.5\nNYC  ▅█  min 5  max 10  average 7.5\n"
+	if buffer.String() != expected {
+		t.Errorf("Should be\n%s\nbut was\n%s", expected, buffer.String())
+	}
+}
@@ -0,0 +1,94 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ekougs/weather-station/config"
+	"github.com/ekougs/weather-station/resources"
+	"github.com/ekougs/weather-station/util"
+)
+
+// application gathers components configured with effective settings
+type application struct {
+	settings     config.Config
+	dataUtils    util.DataUtils
+	timeUtils    util.TimeUtils
+	tempProvider util.TempProvider
+}
+
+// newApplication configures components. Temps stored next to the executable
+// by previous versions are copied on first run of the data directory
+func newApplication(settings config.Config) (application, error) {
+	app := application{settings: settings}
+	isFirstRun := !isDir(settings.DataDir)
+	var err error
+	app.dataUtils, err = newDataUtils(settings)
+	if err != nil {
+		return app, err
+	}
+	if isFirstRun {
+		if err = migrateLegacyTemps(app.dataUtils, settings.DataDir); err != nil {
+			return app, err
+		}
+	}
+
+	app.timeUtils, err = util.NewTimeUtils(app.dataUtils)
+	if err != nil {
+		return app, err
+	}
+
+	app.tempProvider, err = util.NewTempProvider(app.dataUtils)
+	if err != nil {
+		return app, err
+	}
+	app.tempProvider = app.tempProvider.WithModel(settings.Model)
+	if settings.Seed != 0 {
+		app.tempProvider = app.tempProvider.WithSeed(settings.Seed)
+	}
+	return app, nil
+}
+
+// newDataUtils reads cities from the configured file, the one written in the
+// data directory once embedded cities are edited or the embedded ones
+func newDataUtils(settings config.Config) (util.DataUtils, error) {
+	citiesFile := settings.CitiesFile
+	if editedFile := filepath.Join(settings.DataDir, util.CitiesFileName); citiesFile == "" && isFile(editedFile) {
+		citiesFile = editedFile
+	}
+	if citiesFile == "" {
+		return util.NewDataUtilsFromJSON(resources.CitiesJSON, settings.DataDir)
+	}
+	dataUtils, err := util.NewDataUtils(citiesFile)
+	return dataUtils.WithDataDir(settings.DataDir), err
+}
+
+// migrateLegacyTemps copies temps stored next to the executable by previous
+// versions into the data directory
+func migrateLegacyTemps(dataUtils util.DataUtils, dataDir string) error {
+	executable, err := os.Executable()
+	if err != nil {
+		return nil
+	}
+	if resolved, err := filepath.EvalSymlinks(executable); err == nil {
+		executable = resolved
+	}
+	legacyDir := filepath.Join(filepath.Dir(executable), "resources")
+	migrated, err := dataUtils.MigrateTemps(legacyDir)
+	if len(migrated) > 0 {
+		fmt.Fprintf(os.Stderr, "Migrated %s to %s\n", strings.Join(migrated, ", "), dataDir)
+	}
+	return err
+}
+
+func isDir(name string) bool {
+	info, err := os.Stat(name)
+	return err == nil && info.IsDir()
+}
+
+func isFile(name string) bool {
+	info, err := os.Stat(name)
+	return err == nil && info.Mode().IsRegular()
+}
@@ -0,0 +1,128 @@
+package cli
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ekougs/weather-station/config"
+	"github.com/ekougs/weather-station/util"
+)
+
+var sampleColumns = []string{"date", "min", "max"}
+
+// cityFlags change the definition of a city
+type cityFlags struct {
+	name, timeZone, normals, samplesFile *string
+}
+
+func defineCityFlags(flagSet *flag.FlagSet) cityFlags {
+	name := flagSet.String("name", "", "Name of the added or updated city")
+	timeZone := flagSet.String("tz", "", "IANA time zone like Europe/Paris of the added or updated city")
+	normals := flagSet.String("normals", "", "12 monthly normals like 3:8,3:9,... as min:max temps from January, samples are derived from")
+	samplesFile := flagSet.String("samples", "", "JSON file of sample temps like the ones of the cities file")
+	return cityFlags{name, timeZone, normals, samplesFile}
+}
+
+// change returns the change of the city with code set by flags
+func (flags cityFlags) change(code string) (util.CityChange, error) {
+	change := util.CityChange{CityDefinition: util.CityDefinition{Name: *flags.name, Code: code, IanaTZ: *flags.timeZone}}
+	if *flags.normals != "" {
+		for _, normal := range strings.Split(*flags.normals, ",") {
+			bounds := strings.Split(strings.TrimSpace(normal), ":")
+			min, minErr := strconv.Atoi(bounds[0])
+			max, maxErr := 0, minErr
+			if len(bounds) == 2 {
+				max, maxErr = strconv.Atoi(bounds[1])
+			}
+			if minErr != nil || maxErr != nil || len(bounds) != 2 || min >= max {
+				return change, util.InvalidArgumentErrorf("normals", "Normal '%s' should be like min:max with min below max", normal)
+			}
+			change.MonthlyNormals = append(change.MonthlyNormals, []int{min, max})
+		}
+	}
+	if *flags.samplesFile != "" {
+		content, err := os.ReadFile(*flags.samplesFile)
+		if err != nil {
+			return change, err
+		}
+		if err = json.Unmarshal(content, &change.Samples); err != nil {
+			return change, util.InvalidArgumentErrorf("samples", "Cannot read sample temps of %s: %s", *flags.samplesFile, err)
+		}
+	}
+	return change, nil
+}
+
+func setUpCities(flagSet *flag.FlagSet) runner {
+	outputFlags := defineOutputFlags(flagSet, jsonOutput)
+	cityFlags := defineCityFlags(flagSet)
+	return func(settings config.Config, args []string) error {
+		output, err := outputFlags.resolve()
+		if err != nil {
+			return err
+		}
+		dataUtils, err := newDataUtils(settings)
+		if err != nil {
+			return err
+		}
+		if len(args) == 0 {
+			cities, err := dataUtils.GetCities()
+			if err != nil {
+				return err
+			}
+			sort.Sort(cities)
+			return output.print(os.Stdout, cities, cityRecords(cities))
+		}
+
+		if len(args) != 2 {
+			return usageErrorf("Expecting add, update, remove or show followed by a city")
+		}
+		var definition util.CityDefinition
+		switch args[0] {
+		case "show":
+			definition, err = dataUtils.GetCityDefinition(args[1])
+		case "add", "update":
+			var change util.CityChange
+			if change, err = cityFlags.change(args[1]); err != nil {
+				return err
+			}
+			if args[0] == "add" {
+				definition, err = dataUtils.AddCity(change)
+				break
+			}
+			change.Code = ""
+			definition, err = dataUtils.UpdateCity(args[1], change)
+		case "remove":
+			definition, err = dataUtils.RemoveCity(args[1])
+			if err == nil {
+				fmt.Printf("Removed %s %s, its stored temps are kept\n", definition.Code, definition.Name)
+			}
+			return err
+		default:
+			return usageErrorf("Unknown cities command '%s'", args[0])
+		}
+		if err != nil {
+			return err
+		}
+		return output.print(os.Stdout, definition, sampleRecords(definition))
+	}
+}
+
+// sampleRecords are the sample temps of a city, its name and time zone being
+// the summary
+func sampleRecords(definition util.CityDefinition) records {
+	rows := make([][]interface{}, 0, len(definition.Samples))
+	for _, sample := range definition.Samples {
+		row := []interface{}{sample.Time.Format("01-02"), nil, nil}
+		// Ranges of a cities file edited by hand may be malformed
+		for bound := 0; bound < 2 && bound < len(sample.TempRange); bound++ {
+			row[bound+1] = sample.TempRange[bound]
+		}
+		rows = append(rows, row)
+	}
+	return records{sampleColumns, rows, fmt.Sprintf("%s  %s  %s", definition.Code, definition.Name, definition.IanaTZ), nil, nil}
+}
@@ -1,9 +1,10 @@
 package cli
 
 import (
+	"context"
 	"encoding/json"
-	"flag"
 	"fmt"
+	"io"
 	"os"
 	"time"
 
@@ -11,8 +12,12 @@ import (
 )
 
 type instructionParams struct {
-	city, duration string
-	date           time.Time
+	cities          []string
+	duration        string
+	date            time.Time
+	displayLocation *time.Location
+	unit            util.TempUnit
+	output          output
 }
 
 // InstructionHandler handles an instruction from CLI
@@ -20,50 +25,131 @@ type InstructionHandler struct {
 	instructionParams
 }
 
-// NewCLIInstructionHandler creates a cli handler
-func NewCLIInstructionHandler(city string, date time.Time, duration string) InstructionHandler {
-	params := instructionParams{city, duration, date}
+// NewCLIInstructionHandler creates a cli handler for one or several cities
+// Times are rendered in displayLocation or in the city time zone if nil
+func NewCLIInstructionHandler(cities []string, date time.Time, duration string, displayLocation *time.Location) InstructionHandler {
+	params := instructionParams{cities, duration, date, displayLocation, util.Celsius, defaultOutput}
 	return InstructionHandler{params}
 }
 
-// PrintResponse pretty print in JSON format a response matching parameters
-// of the instruction handler
+// WithUnit returns a handler printing temps in unit
+func (handler InstructionHandler) WithUnit(unit util.TempUnit) InstructionHandler {
+	handler.unit = unit
+	return handler
+}
+
+// withOutput returns a handler printing responses as output tells
+func (handler InstructionHandler) withOutput(output output) InstructionHandler {
+	handler.output = output
+	return handler
+}
+
+// PrintResponse prints a response matching parameters of the instruction
+// handler, pretty JSON by default, and leaves on error
 func (handler InstructionHandler) PrintResponse(tempProvider util.TempProvider, timeUtils util.TimeUtils) {
-	var data interface{}
+	IfErrorInformAndLeave(handler.writeResponse(os.Stdout, tempProvider, timeUtils))
+}
+
+// writeResponse writes a response matching parameters of the instruction
+// handler. Responses for several cities are written together, as a JSON
+// array. Temps which could be provided are written before a DatesError
+func (handler InstructionHandler) writeResponse(writer io.Writer, tempProvider util.TempProvider, timeUtils util.TimeUtils) error {
 	if "" == handler.duration {
-		data = handler.getTemp(tempProvider)
-	} else {
-		data = handler.getTemps(tempProvider, timeUtils)
+		cityTemps := make([]util.CityTemp, 0, len(handler.cities))
+		for _, city := range handler.cities {
+			cityTemp, err := handler.getTemp(tempProvider, timeUtils, city)
+			if err != nil {
+				return err
+			}
+			cityTemps = append(cityTemps, cityTemp)
+		}
+		if len(cityTemps) == 1 {
+			return handler.output.print(writer, cityTemps[0], tempRecords(cityTemps))
+		}
+		return handler.output.print(writer, cityTemps, tempRecords(cityTemps))
 	}
-	handler.prettyPrintJSON(data)
+
+	allCityTemps := make([]util.CityTemps, 0, len(handler.cities))
+	allRecords := make([]records, 0, len(handler.cities))
+	for _, city := range handler.cities {
+		cityTemps, err := handler.getTemps(tempProvider, timeUtils, city)
+		if _, isDatesError := err.(util.DatesError); isDatesError {
+			// Temps which could be provided are still worth printing
+			if printErr := handler.output.print(writer, cityTemps, cityTempsRecords(city, cityTemps)); printErr != nil {
+				return printErr
+			}
+		}
+		if err != nil {
+			return err
+		}
+		allCityTemps = append(allCityTemps, cityTemps)
+		allRecords = append(allRecords, cityTempsRecords(city, cityTemps))
+	}
+	if len(allCityTemps) == 1 {
+		return handler.output.print(writer, allCityTemps[0], allRecords[0])
+	}
+	return handler.output.print(writer, allCityTemps, mergeRecords(allRecords))
 }
 
-func (handler InstructionHandler) getTemp(tempProvider util.TempProvider) util.CityTemp {
-	city, date := handler.city, handler.date
+func (handler InstructionHandler) getTemp(tempProvider util.TempProvider, timeUtils util.TimeUtils, city string) (util.CityTemp, error) {
+	date, err := handler.getCityDate(timeUtils, city)
+	if err != nil {
+		return util.CityTemp{}, err
+	}
 	temp, err := tempProvider.Get(city, date)
-	IfErrorInformAndLeave(err)
-	return util.CityTemp{City: city, Time: util.TempTime(date), Temp: temp}
+	if err != nil {
+		return util.CityTemp{}, err
+	}
+	cityTemp := util.NewCityTemp(city, date, temp).InUnit(handler.unit)
+	if handler.displayLocation != nil {
+		return cityTemp.In(handler.displayLocation), nil
+	}
+	return cityTemp, nil
+}
+
+// getTemps returns temps of city for the period of the handler, the ones
+// which could be provided with a DatesError
+func (handler InstructionHandler) getTemps(tempProvider util.TempProvider, timeUtils util.TimeUtils, city string) (util.CityTemps, error) {
+	date, err := handler.getCityDate(timeUtils, city)
+	if err != nil {
+		return util.CityTemps{}, err
+	}
+	datesChan, err := timeUtils.GetDatesForPeriod(context.Background(), date, handler.duration)
+	if err != nil {
+		return util.CityTemps{}, err
+	}
+	cityTemps, err := tempProvider.GetForDates(context.Background(), city, datesChan)
+	cityTemps = cityTemps.InUnit(handler.unit)
+	if handler.displayLocation != nil {
+		cityTemps = cityTemps.In(handler.displayLocation)
+	}
+	return cityTemps, err
 }
 
-func (handler InstructionHandler) getTemps(tempProvider util.TempProvider, timeUtils util.TimeUtils) util.CityTemps {
-	city, date, duration := handler.city, handler.date, handler.duration
-	datesChan, err := timeUtils.GetDatesForPeriod(date, duration)
-	IfErrorInformAndLeave(err)
-	return tempProvider.GetForDates(city, datesChan)
+// getCityDate returns the date of the handler in the time zone of city so that
+// cities are compared at the same instants
+func (handler InstructionHandler) getCityDate(timeUtils util.TimeUtils, city string) (time.Time, error) {
+	location, err := timeUtils.GetLocation(city)
+	if err != nil {
+		return handler.date, err
+	}
+	return handler.date.In(location), nil
 }
 
-func (handler InstructionHandler) prettyPrintJSON(object interface{}) {
+// printJSON writes object as indented JSON
+func printJSON(writer io.Writer, object interface{}) error {
 	prettyJSON, err := json.MarshalIndent(object, "", "    ")
-	IfErrorInformAndLeave(err)
-	fmt.Println(string(prettyJSON))
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(writer, string(prettyJSON))
+	return err
 }
 
-// IfErrorInformAndLeave is an utility function to print error, show information
-// and leave program with error status
+// IfErrorInformAndLeave is an utility function to print error on standard
+// error and leave program with the exit status of its class
 func IfErrorInformAndLeave(err error) {
 	if err != nil {
-		fmt.Println(err)
-		flag.Usage()
-		os.Exit(1)
+		os.Exit(errorReport{}.write(os.Stderr, err, ""))
 	}
 }
@@ -0,0 +1,497 @@
+package cli
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/ekougs/weather-station/config"
+	"github.com/ekougs/weather-station/server"
+	"github.com/ekougs/weather-station/util"
+)
+
+// defaultCity is the city of temps unless another one is configured
+const defaultCity = "DKR"
+
+// runner runs a command once its flags are parsed with remaining arguments
+type runner func(settings config.Config, args []string) error
+
+// command is a subcommand like get or serve with its own flags
+// settings are the keys of config settings available as flags, all if none
+// setUp defines the other flags and returns the function running the command
+type command struct {
+	name, arguments, summary string
+	settings                 []string
+	setUp                    func(flagSet *flag.FlagSet) runner
+}
+
+var querySettings = []string{config.DataDirKey, config.CitiesFileKey, config.DefaultCityKey, config.SeedKey, config.UnitsKey, config.ModelKey}
+var serveSettings = []string{config.DataDirKey, config.CitiesFileKey, config.ListenKey, config.SeedKey, config.UnitsKey, config.ModelKey}
+var dataSettings = []string{config.DataDirKey, config.CitiesFileKey}
+
+var commands = []command{
+	{"get", "", "Print the temp of a city at a date", querySettings, setUpGet},
+	{"range", "", "Print the temps of a city every day of a period ending at a date, with statistics", querySettings, setUpRange},
+	{"cities", "[add|update|remove|show CITY]", "List handled cities, or add, update, remove or show one in the cities file", dataSettings, setUpCities},
+	{"serve", "", "Launch the HTTP server offering every feature", serveSettings, setUpServe},
+	{"shell", "", "Explore temps of cities interactively, loading data once", querySettings, setUpShell},
+	{"validate", "", "Check cities definitions and stored temps", dataSettings, setUpValidate},
+	{"export", "", "Print temps stored for cities as JSON", dataSettings, setUpExport},
+	{"import", "FILE", "Store temps of a JSON file printed by export or observed temps of a CSV file, - for standard input", dataSettings, setUpImport},
+	{"config", "show", "Print effective settings and where they come from : flag, " + config.EnvPrefix + "* environment variable, config file or default", nil, setUpConfig},
+}
+
+// Run runs the command named by the first argument with the next ones and
+// returns the exit status. Without command, flags of previous versions are
+// handled like get, range or serve ones
+func Run(args []string) int {
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		return runLegacy(args)
+	}
+	if args[0] == "help" {
+		printUsage(os.Stdout)
+		return 0
+	}
+	command, found := getCommand(args[0])
+	if !found {
+		hint := fmt.Sprintf("Run '%s help' for commands.", programName())
+		return errorReport{}.write(os.Stderr, usageErrorf("Unknown command '%s'", args[0]), hint)
+	}
+	return command.run(args[1:])
+}
+
+func getCommand(name string) (command, bool) {
+	for _, group := range [][]command{commands, hiddenCommands} {
+		for _, command := range group {
+			if command.name == name {
+				return command, true
+			}
+		}
+	}
+	return command{}, false
+}
+
+// run runs the command and returns the exit status. Help asked with -h is
+// printed on standard output, errors are reported on standard error as
+// -quiet and -json-errors flags tell
+func (command command) run(args []string) int {
+	flagSet, defaults, run, report := command.newFlagSet()
+	flagSet.SetOutput(io.Discard)
+	// Flags after arguments must be parsed before settings are loaded
+	args, err := parseInterspersed(flagSet, args)
+	switch {
+	case err == flag.ErrHelp:
+		flagSet.SetOutput(os.Stdout)
+		flagSet.Usage()
+		return exitOK
+	case err != nil:
+		err = usageError{err}
+	case command.arguments == "" && len(args) > 0:
+		err = usageErrorf("Unexpected arguments %s", strings.Join(args, " "))
+	default:
+		var settings config.Config
+		settings, err = config.Load(defaults, flagSet)
+		if err == nil {
+			err = run(settings, args)
+		}
+	}
+	if errors.Is(err, flag.ErrHelp) {
+		// Asked by the command itself
+		flagSet.SetOutput(os.Stdout)
+		flagSet.Usage()
+		return exitOK
+	}
+	if err == nil {
+		return exitOK
+	}
+	hint := ""
+	if exitStatus(err) == exitUsage {
+		hint = fmt.Sprintf("%s\nRun '%s %s -h' for help.", command.usageLine(), programName(), command.name)
+	}
+	return report.write(os.Stderr, err, hint)
+}
+
+func (command command) usageLine() string {
+	return strings.TrimSpace(fmt.Sprintf("Usage: %s %s [flags] %s", programName(), command.name, command.arguments))
+}
+
+func (command command) newFlagSet() (*flag.FlagSet, config.Config, runner, errorReport) {
+	flagSet := flag.NewFlagSet(command.name, flag.ContinueOnError)
+	defaults := config.Config{DataDir: config.DefaultDataDir(), Listen: server.DefaultAddress, DefaultCity: defaultCity}
+	config.DefineFlags(flagSet, defaults, command.settings...)
+	run := command.setUp(flagSet)
+	report := defineErrorFlags(flagSet)
+	flagSet.Usage = func() {
+		output := flagSet.Output()
+		fmt.Fprintf(output, "%s\n%s\n\nFlags:\n", command.usageLine(), command.summary)
+		flagSet.PrintDefaults()
+		if flagSet.Lookup("c") != nil {
+			printCities(output, defaults, flagSet)
+		}
+	}
+	return flagSet, defaults, run, report
+}
+
+// printCities prints the cities handled according to the settings known so far
+func printCities(output io.Writer, defaults config.Config, flagSet *flag.FlagSet) {
+	settings, err := config.Load(defaults, flagSet)
+	if err != nil {
+		return
+	}
+	dataUtils, err := newDataUtils(settings)
+	if err != nil {
+		return
+	}
+	cities, err := dataUtils.GetCities()
+	if err != nil {
+		return
+	}
+	citiesToString := make([]string, 0, len(cities))
+	for _, city := range cities {
+		citiesToString = append(citiesToString, city.String())
+	}
+	fmt.Fprintf(output, "Cities : %s\n", strings.Join(citiesToString, ", "))
+}
+
+func printUsage(output io.Writer) {
+	fmt.Fprintf(output, "Usage: %s <command> [flags] [arguments]\n\nCommands:\n", programName())
+	tabWriter := tabwriter.NewWriter(output, 0, 4, 2, ' ', 0)
+	for _, command := range commands {
+		fmt.Fprintf(tabWriter, "  %s %s\t%s\n", command.name, command.arguments, command.summary)
+	}
+	tabWriter.Flush()
+	fmt.Fprintf(output, "\nRun '%s <command> -h' for the flags of a command.\n", programName())
+	fmt.Fprintln(output, "Without command, flags of get, range and serve are accepted : -s serves, -D prints a range, otherwise get.")
+	fmt.Fprintf(output, "\n%s\n", exitStatusesHelp)
+}
+
+func programName() string {
+	return filepath.Base(os.Args[0])
+}
+
+// runLegacy handles flags without command like previous versions : -s runs
+// serve ignoring flags it does not have, -D runs range, otherwise get runs
+func runLegacy(args []string) int {
+	legacyFlagSet, serveMode, report, flagSets := newLegacyFlagSet()
+	legacyFlagSet.SetOutput(io.Discard)
+	if err := legacyFlagSet.Parse(args); err == flag.ErrHelp {
+		legacyFlagSet.SetOutput(os.Stdout)
+		legacyFlagSet.Usage()
+		return exitOK
+	} else if err != nil {
+		hint := fmt.Sprintf("Run '%s -h' for help.", programName())
+		return report.write(os.Stderr, usageError{err}, hint)
+	}
+
+	name := "get"
+	switch {
+	case legacyFlagSet.NArg() > 0 && legacyFlagSet.Arg(0) == "config":
+		name = "config"
+	case *serveMode:
+		name = "serve"
+	case strings.TrimSpace(legacyFlagSet.Lookup("D").Value.String()) != "":
+		name = "range"
+	}
+	var commandArgs []string
+	legacyFlagSet.Visit(func(legacyFlag *flag.Flag) {
+		if flagSets[name].Lookup(legacyFlag.Name) != nil {
+			commandArgs = append(commandArgs, "-"+legacyFlag.Name+"="+legacyFlag.Value.String())
+		}
+	})
+	if name == "config" {
+		commandArgs = append(commandArgs, legacyFlagSet.Args()[1:]...)
+	}
+	command, _ := getCommand(name)
+	return command.run(commandArgs)
+}
+
+// newLegacyFlagSet returns the flags accepted without command : -s and the
+// ones of get, range, serve and config commands, whose flag sets are returned
+// by name
+func newLegacyFlagSet() (*flag.FlagSet, *bool, errorReport, map[string]*flag.FlagSet) {
+	legacyFlagSet := flag.NewFlagSet(programName(), flag.ContinueOnError)
+	serveMode := legacyFlagSet.Bool("s", false, "Launch HTTP server like serve command, ignoring flags it does not have")
+	report := defineErrorFlags(legacyFlagSet)
+	flagSets := make(map[string]*flag.FlagSet)
+	for _, name := range []string{"get", "range", "serve", "config"} {
+		command, _ := getCommand(name)
+		flagSets[name], _, _, _ = command.newFlagSet()
+		flagSets[name].VisitAll(func(commandFlag *flag.Flag) {
+			if legacyFlagSet.Lookup(commandFlag.Name) != nil {
+				return
+			}
+			if isBoolFlag(commandFlag) {
+				legacyFlagSet.Bool(commandFlag.Name, commandFlag.DefValue == "true", commandFlag.Usage)
+				return
+			}
+			legacyFlagSet.String(commandFlag.Name, commandFlag.DefValue, commandFlag.Usage)
+		})
+	}
+	legacyFlagSet.Usage = func() {
+		printUsage(legacyFlagSet.Output())
+		fmt.Fprintln(legacyFlagSet.Output(), "\nFlags without command :")
+		legacyFlagSet.PrintDefaults()
+	}
+	return legacyFlagSet, serveMode, report, flagSets
+}
+
+// isBoolFlag tells whether commandFlag is set without value
+func isBoolFlag(commandFlag *flag.Flag) bool {
+	boolFlag, isBool := commandFlag.Value.(interface{ IsBoolFlag() bool })
+	return isBool && boolFlag.IsBoolFlag()
+}
+
+// parseInterspersed parses flags of flagSet found anywhere in args and
+// returns the other arguments. Arguments after -- are never flags
+func parseInterspersed(flagSet *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := flagSet.Parse(args); err != nil {
+			return nil, err
+		}
+		remaining := flagSet.Args()
+		if len(remaining) == 0 {
+			return positional, nil
+		}
+		if parsed := len(args) - len(remaining); parsed > 0 && args[parsed-1] == "--" {
+			return append(positional, remaining...), nil
+		}
+		positional = append(positional, remaining[0])
+		args = remaining[1:]
+	}
+}
+
+// timeFlags tell the date of temps and how to render them
+type timeFlags struct {
+	formattedDate, dstPolicy, displayZone *string
+}
+
+func defineTimeFlags(flagSet *flag.FlagSet) timeFlags {
+	dateExample := "Date format example : " + util.LocalTimeFormat + " in city time zone, any RFC 3339 time like 2006-01-02T15:04:05.5-07:00 or relative like " + util.RelativeTimeExamples
+	formattedDate := flagSet.String("d", "now", dateExample)
+
+	displayZoneHelpMessage := "Time zone like UTC or America/New_York, or epoch for Unix seconds, in which times are printed. City time zone by default"
+	displayZone := flagSet.String("tz", "", displayZoneHelpMessage)
+
+	return timeFlags{formattedDate, defineDSTFlag(flagSet), displayZone}
+}
+
+func defineDSTFlag(flagSet *flag.FlagSet) *string {
+	dstPolicyHelpMessage := "Policy for local times missing or repeated because of daylight saving time : " + util.DSTPolicies
+	return flagSet.String("dst", util.DSTEarliest.String(), dstPolicyHelpMessage)
+}
+
+// printTemps prints the temp of the configured cities at the date of flags or
+// their temps for the period ending at this date if duration is not empty
+func printTemps(settings config.Config, flags timeFlags, outputFlags outputFlags, duration string) error {
+	output, err := outputFlags.resolve()
+	if err != nil {
+		return err
+	}
+	app, err := newApplication(settings)
+	if err != nil {
+		return err
+	}
+	dstPolicy, err := util.ParseDSTPolicy(*flags.dstPolicy)
+	if err != nil {
+		return err
+	}
+	timeUtils := app.timeUtils.WithDSTPolicy(dstPolicy)
+
+	// Dates are read in the time zone of the first city
+	cityCodes, err := getCityCodes(app.dataUtils, settings.DefaultCity)
+	if err != nil {
+		return err
+	}
+	if len(cityCodes) == 0 {
+		return util.NotFoundErrorf("city", "No city to print temps of")
+	}
+	var date time.Time
+	date, err = timeUtils.GetTime(*flags.formattedDate, cityCodes[0])
+	if err != nil {
+		return err
+	}
+
+	var displayLocation *time.Location
+	if "" != *flags.displayZone {
+		displayLocation, err = util.LoadDisplayLocation(*flags.displayZone)
+		if err != nil {
+			return err
+		}
+	}
+
+	cliInstrHandler := NewCLIInstructionHandler(cityCodes, date, strings.TrimSpace(duration), displayLocation).WithUnit(settings.Units).withOutput(output)
+	return cliInstrHandler.writeResponse(os.Stdout, app.tempProvider, timeUtils)
+}
+
+func setUpGet(flagSet *flag.FlagSet) runner {
+	timeFlags := defineTimeFlags(flagSet)
+	outputFlags := defineOutputFlags(flagSet, jsonOutput)
+	return func(settings config.Config, args []string) error {
+		return printTemps(settings, timeFlags, outputFlags, "")
+	}
+}
+
+func setUpRange(flagSet *flag.FlagSet) runner {
+	timeFlags := defineTimeFlags(flagSet)
+	outputFlags := defineOutputFlags(flagSet, jsonOutput)
+	duration := flagSet.String("D", "", "Expecting duration like 1Y3M2D, 1Y2M, 3M2D or 3D")
+	return func(settings config.Config, args []string) error {
+		if strings.TrimSpace(*duration) == "" {
+			return util.InvalidArgumentErrorf("duration", "Expecting a period with -D like 1Y3M2D, 1Y2M, 3M2D or 3D")
+		}
+		return printTemps(settings, timeFlags, outputFlags, *duration)
+	}
+}
+
+func setUpServe(flagSet *flag.FlagSet) runner {
+	dstPolicyName := defineDSTFlag(flagSet)
+
+	liveIntervalHelpMessage := "Real time between two readings of live feeds, each one for the next simulated hour"
+	liveInterval := flagSet.Duration("live-interval", time.Hour, liveIntervalHelpMessage)
+
+	address := flagSet.String("addr", "", "IP address or host name the server binds to, overriding the one of -listen")
+	port := flagSet.Int("port", 0, "TCP port the server listens on, overriding the one of -listen")
+	unixSocket := flagSet.String("socket", "", "Unix domain socket path the server listens on instead of a TCP port")
+	tlsCertFile := flagSet.String("tls-cert", "", "PEM certificate file to serve HTTPS, requires -tls-key")
+	tlsKeyFile := flagSet.String("tls-key", "", "PEM private key file to serve HTTPS, requires -tls-cert")
+
+	return func(settings config.Config, args []string) error {
+		settings, err := overrideListenAddress(settings, flagSet, *address, *port)
+		if err != nil {
+			return err
+		}
+		if err = server.CheckLiveInterval(*liveInterval, "live-interval"); err != nil {
+			return err
+		}
+		app, err := newApplication(settings)
+		if err != nil {
+			return err
+		}
+		dstPolicy, err := util.ParseDSTPolicy(*dstPolicyName)
+		if err != nil {
+			return err
+		}
+		weatherServer := server.NewWeatherServer(app.tempProvider, app.timeUtils.WithDSTPolicy(dstPolicy), app.dataUtils).
+			WithLiveInterval(*liveInterval).
+			WithAddress(settings.Listen).
+			WithUnixSocket(*unixSocket).
+			WithTLS(*tlsCertFile, *tlsKeyFile).
+			WithAdminSecret(settings.AdminSecret).
+			WithUnit(settings.Units)
+		return weatherServer.LaunchServer()
+	}
+}
+
+// overrideListenAddress returns settings whose listen address has the host
+// of -addr flag and the port of -port flag if they are set
+func overrideListenAddress(settings config.Config, flagSet *flag.FlagSet, address string, port int) (config.Config, error) {
+	setFlags := make(map[string]bool)
+	flagSet.Visit(func(setFlag *flag.Flag) {
+		setFlags[setFlag.Name] = true
+	})
+	if !setFlags["addr"] && !setFlags["port"] {
+		return settings, nil
+	}
+	listenHost, listenPort, err := net.SplitHostPort(settings.Listen)
+	if err != nil {
+		return settings, util.InvalidArgumentErrorf(config.ListenKey, "Invalid listen address '%s': %s", settings.Listen, err)
+	}
+	if setFlags["addr"] {
+		listenHost = address
+	}
+	if setFlags["port"] {
+		listenPort = strconv.Itoa(port)
+	}
+	return settings.Override(config.ListenKey, net.JoinHostPort(listenHost, listenPort))
+}
+
+func setUpValidate(flagSet *flag.FlagSet) runner {
+	return func(settings config.Config, args []string) error {
+		dataUtils, err := newDataUtils(settings)
+		if err != nil {
+			return err
+		}
+		problems := dataUtils.Validate()
+		for _, problem := range problems {
+			fmt.Println(problem)
+		}
+		if len(problems) > 0 {
+			return util.DataErrorf("Found %d problems", len(problems))
+		}
+		fmt.Println("Cities and stored temps are valid")
+		return nil
+	}
+}
+
+func setUpExport(flagSet *flag.FlagSet) runner {
+	cityNames := flagSet.String("c", "", "Comma separated IATA codes or names of cities to export, all by default")
+	outputFile := flagSet.String("o", "", "File written instead of standard output")
+	return func(settings config.Config, args []string) error {
+		app, err := newApplication(settings)
+		if err != nil {
+			return err
+		}
+		cityCodes, err := getCityCodes(app.dataUtils, *cityNames)
+		if err != nil {
+			return err
+		}
+		exported := make(map[string][]util.Temp, len(cityCodes))
+		for _, cityCode := range cityCodes {
+			exported[cityCode], err = app.dataUtils.GetStoredTemps(cityCode)
+			if err != nil {
+				return err
+			}
+		}
+		if *outputFile == "" {
+			return printJSON(os.Stdout, exported)
+		}
+		output, err := os.Create(*outputFile)
+		if err != nil {
+			return err
+		}
+		err = printJSON(output, exported)
+		if closeErr := output.Close(); err == nil {
+			err = closeErr
+		}
+		return err
+	}
+}
+
+// getCityCodes returns codes of comma separated city names, all cities if
+// empty
+func getCityCodes(dataUtils util.DataUtils, cityNames string) ([]string, error) {
+	var cityCodes []string
+	if strings.TrimSpace(cityNames) == "" {
+		cities, err := dataUtils.GetCities()
+		for _, city := range cities {
+			cityCodes = append(cityCodes, city.Code)
+		}
+		return cityCodes, err
+	}
+	for _, cityName := range strings.Split(cityNames, ",") {
+		cityCode, err := dataUtils.GetCityCode(strings.TrimSpace(cityName))
+		if err != nil {
+			return nil, err
+		}
+		cityCodes = append(cityCodes, cityCode)
+	}
+	return cityCodes, nil
+}
+
+func setUpConfig(flagSet *flag.FlagSet) runner {
+	return func(settings config.Config, args []string) error {
+		if len(args) != 1 || args[0] != "show" {
+			return usageErrorf("Expecting config show")
+		}
+		return settings.Show(os.Stdout)
+	}
+}
@@ -0,0 +1,76 @@
+package cli
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// isolate keeps commands away from the config and data of the user
+func isolate(t *testing.T) (defaultDataHome string) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	defaultDataHome = t.TempDir()
+	t.Setenv("XDG_DATA_HOME", defaultDataHome)
+	stdout := os.Stdout
+	os.Stdout, _ = os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	t.Cleanup(func() {
+		os.Stdout.Close()
+		os.Stdout = stdout
+	})
+	return defaultDataHome
+}
+
+func TestParseInterspersedFindsFlagsAfterArguments(t *testing.T) {
+	flagSet := flag.NewFlagSet("import", flag.ContinueOnError)
+	dataDir := flagSet.String("data-dir", "", "")
+	dryRun := flagSet.Bool("dry-run", false, "")
+	args, err := parseInterspersed(flagSet, []string{"readings.csv", "-data-dir", "/tmp/d", "-", "-dry-run", "--", "-city"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(args, " ") != "readings.csv - -city" || *dataDir != "/tmp/d" || !*dryRun {
+		t.Errorf("Unexpected arguments %v, data dir %s and dry run %t", args, *dataDir, *dryRun)
+	}
+}
+
+func TestImportUsesDataDirFlagAfterFile(t *testing.T) {
+	defaultDataHome := isolate(t)
+	csvFile := filepath.Join(t.TempDir(), "readings.csv")
+	os.WriteFile(csvFile, []byte("timestamp,city,temperature\n2015-04-02T17:00:00,Paris,12\n"), 0644)
+	dataDir := filepath.Join(t.TempDir(), "data")
+
+	if status := Run([]string{"import", csvFile, "-data-dir", dataDir}); status != exitOK {
+		t.Fatalf("Import should succeed instead of exiting with %d", status)
+	}
+	if stored, _ := filepath.Glob(filepath.Join(dataDir, "*")); len(stored) == 0 {
+		t.Errorf("Observations should be stored in %s", dataDir)
+	}
+	if stored, _ := filepath.Glob(filepath.Join(defaultDataHome, "*")); len(stored) > 0 {
+		t.Errorf("Nothing should be stored in default data directory instead of %v", stored)
+	}
+}
+
+func TestCitiesUsesDataDirFlagAfterArguments(t *testing.T) {
+	defaultDataHome := isolate(t)
+	dataDir := filepath.Join(t.TempDir(), "data")
+	status := Run([]string{"cities", "add", "XYZ", "-data-dir", dataDir, "-name", "Testville", "-tz", "Europe/Paris",
+		"-normals", "3:8,3:9,5:13,7:16,11:20,14:23,16:25,16:25,13:21,10:16,6:11,4:8"})
+	if status != exitOK {
+		t.Fatalf("Adding city should succeed instead of exiting with %d", status)
+	}
+	if _, err := os.Stat(filepath.Join(dataDir, "cities.json")); err != nil {
+		t.Errorf("Cities file should be written in %s: %s", dataDir, err)
+	}
+	if stored, _ := filepath.Glob(filepath.Join(defaultDataHome, "*")); len(stored) > 0 {
+		t.Errorf("Nothing should be written in default data directory instead of %v", stored)
+	}
+}
+
+func TestUnexpectedArgumentsAreUsageErrors(t *testing.T) {
+	isolate(t)
+	if status := Run([]string{"validate", "-quiet", "now"}); status != exitUsage {
+		t.Errorf("Arguments of a command without any should exit with %d instead of %d", exitUsage, status)
+	}
+}
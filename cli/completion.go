@@ -0,0 +1,187 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/ekougs/weather-station/config"
+	"github.com/ekougs/weather-station/resources"
+)
+
+// completeCitiesCommand names the hidden command printing city codes and names
+// for completion scripts
+const completeCitiesCommand = "__complete-cities"
+
+// hiddenCommands are run by completion scripts and not listed in help
+var hiddenCommands []command
+
+func init() {
+	// Registered here since completion scripts describe every command
+	commands = append(commands, command{"completion", "bash|zsh|fish", "Print the script completing commands, flags and cities in a shell", nil, setUpCompletion})
+	hiddenCommands = []command{{completeCitiesCommand, "", "Print codes and names of cities, one per line", dataSettings, setUpCompleteCities}}
+}
+
+var completionShells = []string{"bash", "zsh", "fish"}
+
+// completionCommand describes a command to completion scripts
+// Arguments are completed among words, or as files if Files is set
+type completionCommand struct {
+	Name, Summary string
+	Flags         []completionFlag
+	Arguments     []string
+	Files         bool
+}
+
+// completionFlag describes a flag, without the dash, to completion scripts
+type completionFlag struct {
+	Name, Usage string
+	Bool        bool
+}
+
+// completionScript is what completion script templates describe
+// ValueFlags are the flags taking a file or a free value, unlike c and D
+// whose values are cities and durations
+type completionScript struct {
+	Program, Function, CitiesCommand string
+	Commands                         []completionCommand
+	Legacy                           completionCommand
+	ValueFlags                       []string
+}
+
+func setUpCompletion(flagSet *flag.FlagSet) runner {
+	return func(settings config.Config, args []string) error {
+		if len(args) != 1 || !contains(completionShells, args[0]) {
+			return usageErrorf("Expecting one of %s", strings.Join(completionShells, ", "))
+		}
+		scriptTemplate, err := template.New(args[0]+".tmpl").Funcs(template.FuncMap{
+			"quote":        quote,
+			"fishQuote":    fishQuote,
+			"fishValue":    fishValue,
+			"describe":     describe,
+			"join":         strings.Join,
+			"flagNames":    flagNames,
+			"commandNames": commandNames,
+		}).ParseFS(resources.Completion, "completion/"+args[0]+".tmpl")
+		if err != nil {
+			return err
+		}
+		return scriptTemplate.Execute(os.Stdout, newCompletionScript())
+	}
+}
+
+func newCompletionScript() completionScript {
+	program := programName()
+	script := completionScript{program, strings.NewReplacer("-", "_", ".", "_").Replace(program), completeCitiesCommand, nil, completionCommand{}, nil}
+	valueFlags := make(map[string]bool)
+	describeFlags := func(flagSet *flag.FlagSet) []completionFlag {
+		var flags []completionFlag
+		flagSet.VisitAll(func(commandFlag *flag.Flag) {
+			flags = append(flags, completionFlag{commandFlag.Name, commandFlag.Usage, isBoolFlag(commandFlag)})
+			if !isBoolFlag(commandFlag) && commandFlag.Name != "c" && commandFlag.Name != "D" {
+				valueFlags["-"+commandFlag.Name] = true
+			}
+		})
+		return flags
+	}
+
+	for _, command := range commands {
+		flagSet, _, _, _ := command.newFlagSet()
+		completion := completionCommand{command.name, command.summary, describeFlags(flagSet), nil, false}
+		// Only the first argument is completed, among words or as a file
+		firstArgument := strings.Trim(strings.SplitN(command.arguments, " ", 2)[0], "[]")
+		if strings.ToUpper(firstArgument) == firstArgument {
+			completion.Files = firstArgument == "FILE"
+		} else {
+			completion.Arguments = strings.Split(firstArgument, "|")
+		}
+		script.Commands = append(script.Commands, completion)
+	}
+	script.Commands = append(script.Commands, completionCommand{"help", "Print commands", nil, nil, false})
+	legacyFlagSet, _, _, _ := newLegacyFlagSet()
+	script.Legacy = completionCommand{"", "", describeFlags(legacyFlagSet), nil, false}
+
+	for valueFlag := range valueFlags {
+		script.ValueFlags = append(script.ValueFlags, valueFlag)
+	}
+	sort.Strings(script.ValueFlags)
+	return script
+}
+
+// contains tells whether words has word
+func contains(words []string, word string) bool {
+	for _, candidate := range words {
+		if candidate == word {
+			return true
+		}
+	}
+	return false
+}
+
+func flagNames(command completionCommand) []string {
+	names := make([]string, 0, len(command.Flags))
+	for _, commandFlag := range command.Flags {
+		names = append(names, "-"+commandFlag.Name)
+	}
+	return names
+}
+
+func commandNames(commands []completionCommand) []string {
+	names := make([]string, 0, len(commands))
+	for _, command := range commands {
+		names = append(names, command.Name)
+	}
+	return names
+}
+
+// quote returns value as a single quoted bash or zsh word
+func quote(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+}
+
+// fishQuote returns value as a single quoted fish word
+func fishQuote(value string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, "'", `\'`).Replace(value) + "'"
+}
+
+// fishValue returns the fish complete options of the value of commandFlag :
+// cities, durations, files or none for bool flags
+func fishValue(function string, commandFlag completionFlag) string {
+	switch {
+	case commandFlag.Name == "c":
+		return " -r -a '(__" + function + "_cities)'"
+	case commandFlag.Name == "D":
+		return " -r -a '(__" + function + "_durations)'"
+	case !commandFlag.Bool:
+		return " -r -F"
+	}
+	return ""
+}
+
+// describe returns a zsh _describe item for name
+func describe(name, description string) string {
+	return quote(strings.ReplaceAll(name, ":", `\:`) + ":" + description)
+}
+
+func setUpCompleteCities(flagSet *flag.FlagSet) runner {
+	return func(settings config.Config, args []string) error {
+		dataUtils, err := newDataUtils(settings)
+		if err != nil {
+			return err
+		}
+		cities, err := dataUtils.GetCities()
+		if err != nil {
+			return err
+		}
+		for _, city := range cities {
+			fmt.Println(city.Code)
+		}
+		for _, city := range cities {
+			fmt.Println(city.Name)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,41 @@
+package cli
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestCompletionScriptDescribesCommands(t *testing.T) {
+	script := newCompletionScript()
+	byName := make(map[string]completionCommand)
+	for _, command := range script.Commands {
+		byName[command.Name] = command
+	}
+	if !byName["import"].Files || strings.Join(byName["cities"].Arguments, " ") != "add update remove show" ||
+		strings.Join(byName["completion"].Arguments, " ") != "bash zsh fish" {
+		t.Errorf("Unexpected completion of arguments %+v %+v", byName["import"], byName["cities"])
+	}
+	if !contains(flagNames(byName["get"]), "-c") || !contains(script.ValueFlags, "-data-dir") || contains(script.ValueFlags, "-c") {
+		t.Errorf("Unexpected completion of flags %v, value flags %v", flagNames(byName["get"]), script.ValueFlags)
+	}
+}
+
+func TestCompletionScriptsAreRendered(t *testing.T) {
+	isolate(t)
+	for _, shell := range completionShells {
+		script, err := os.CreateTemp(t.TempDir(), shell)
+		if err != nil {
+			t.Fatal(err)
+		}
+		stdout := os.Stdout
+		os.Stdout = script
+		status := Run([]string{"completion", shell})
+		os.Stdout = stdout
+		script.Close()
+		content, _ := os.ReadFile(script.Name())
+		if status != exitOK || !strings.Contains(string(content), "__complete-cities") || !strings.Contains(string(content), "import") {
+			t.Errorf("%s script should complete commands and cities, exit status %d:\n%s", shell, status, content)
+		}
+	}
+}
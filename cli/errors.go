@@ -0,0 +1,122 @@
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+
+	"github.com/ekougs/weather-station/util"
+)
+
+// Exit statuses telling scripts which class of error stopped a command
+const (
+	exitOK = iota
+	exitFailure
+	exitUsage
+	exitInvalidArgument
+	exitNotFound
+	exitData
+	exitIO
+)
+
+// exitStatusesHelp documents exit statuses in the help
+const exitStatusesHelp = `Exit statuses :
+  0  Success
+  1  Unexpected failure
+  2  Wrong command line : unknown command or flag, unexpected arguments
+  3  Invalid input like a date, a duration or a setting
+  4  Unknown city
+  5  Malformed cities or stored temps
+  6  File or network I/O failure`
+
+var exitStatusByErrorKind = map[util.ErrorKind]int{
+	util.InternalError:        exitFailure,
+	util.InvalidArgumentError: exitInvalidArgument,
+	util.NotFoundError:        exitNotFound,
+	util.DataError:            exitData,
+}
+
+// usageError is an error about the command line itself, like an unexpected
+// argument, reported with a hint to the help of the command
+type usageError struct {
+	error
+}
+
+// Unwrap returns the underlying error
+func (err usageError) Unwrap() error {
+	return err.error
+}
+
+func usageErrorf(format string, args ...interface{}) error {
+	return usageError{fmt.Errorf(format, args...)}
+}
+
+// exitStatus returns the exit status of the class of err
+func exitStatus(err error) int {
+	var pathErr *fs.PathError
+	var linkErr *os.LinkError
+	var syscallErr *os.SyscallError
+	switch {
+	case err == nil:
+		return exitOK
+	case errors.As(err, &usageError{}):
+		return exitUsage
+	case util.GetErrorKind(err) != util.InternalError:
+		return exitStatusByErrorKind[util.GetErrorKind(err)]
+	case errors.As(err, &pathErr), errors.As(err, &linkErr), errors.As(err, &syscallErr):
+		return exitIO
+	}
+	return exitFailure
+}
+
+// errorCode names the class of err in JSON error reports
+func errorCode(status int, err error) string {
+	switch status {
+	case exitUsage:
+		return "usage"
+	case exitIO:
+		return "io"
+	}
+	return util.GetErrorKind(err).String()
+}
+
+// errorReport tells how errors are written on standard error : a message by
+// default, nothing when quiet, a JSON object for scripts
+type errorReport struct {
+	quiet, json *bool
+}
+
+func defineErrorFlags(flagSet *flag.FlagSet) errorReport {
+	quiet := flagSet.Bool("quiet", false, "Write nothing on standard error, only the exit status tells errors")
+	json := flagSet.Bool("json-errors", false, "Write errors on standard error as JSON objects with code, message, field and exit_status")
+	return errorReport{quiet, json}
+}
+
+// errorJSON is the JSON object written for an error with -json-errors
+type errorJSON struct {
+	Code       string `json:"code"`
+	Message    string `json:"message"`
+	Field      string `json:"field,omitempty"`
+	ExitStatus int    `json:"exit_status"`
+}
+
+// write writes err with hint, if any, as report tells and returns its exit
+// status
+func (report errorReport) write(writer io.Writer, err error, hint string) int {
+	status := exitStatus(err)
+	switch {
+	case report.quiet != nil && *report.quiet:
+	case report.json != nil && *report.json:
+		json.NewEncoder(writer).Encode(errorJSON{errorCode(status, err), err.Error(), util.GetErrorField(err), status})
+	default:
+		fmt.Fprintf(writer, "%s: %s\n", programName(), err)
+		if hint != "" {
+			fmt.Fprintln(writer, hint)
+		}
+	}
+	return status
+}
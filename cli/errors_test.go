@@ -0,0 +1,92 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/ekougs/weather-station/util"
+)
+
+func TestExitStatusTellsClassOfError(t *testing.T) {
+	_, openErr := os.Open("/nowhere/temps.json")
+	linkErr := os.Rename("/nowhere/a", "/nowhere/b")
+	for _, test := range []struct {
+		err            error
+		expectedStatus int
+		expectedCode   string
+	}{
+		{nil, exitOK, "internal"},
+		{errors.New("Unexpected"), exitFailure, "internal"},
+		{usageErrorf("Unexpected arguments now"), exitUsage, "usage"},
+		{fmt.Errorf("Cannot parse: %w", usageErrorf("flag provided but not defined: -x")), exitUsage, "usage"},
+		{util.InvalidArgumentErrorf("date", "Bad date"), exitInvalidArgument, "invalid_argument"},
+		{util.NotFoundErrorf("city", "Have not found city Nowhere"), exitNotFound, "not_found"},
+		{fmt.Errorf("Line 3: %w", util.NotFoundErrorf("city", "Have not found city Nowhere")), exitNotFound, "not_found"},
+		{util.DataErrorf("Cannot read cities"), exitData, "data"},
+		{openErr, exitIO, "io"},
+		{fmt.Errorf("Cannot read cities: %w", openErr), exitIO, "io"},
+		{linkErr, exitIO, "io"},
+	} {
+		status := exitStatus(test.err)
+		if status != test.expectedStatus {
+			t.Errorf("Exit status of '%v' should be %d instead of %d", test.err, test.expectedStatus, status)
+		}
+		if test.err != nil && errorCode(status, test.err) != test.expectedCode {
+			t.Errorf("Code of '%v' should be %s instead of %s", test.err, test.expectedCode, errorCode(status, test.err))
+		}
+	}
+}
+
+func TestErrorReportWritesMessageJSONOrNothing(t *testing.T) {
+	err := util.NotFoundErrorf("city", "Have not found city Nowhere")
+	quiet, asJSON := false, false
+	report := errorReport{&quiet, &asJSON}
+
+	var written bytes.Buffer
+	status := report.write(&written, err, "Run 'prog get -h' for help.")
+	expected := fmt.Sprintf("%s: Have not found city Nowhere\nRun 'prog get -h' for help.\n", programName())
+	if status != exitNotFound || written.String() != expected {
+		t.Errorf("Expecting status %d and\n%s\ninstead of %d and\n%s", exitNotFound, expected, status, written.String())
+	}
+
+	written.Reset()
+	asJSON = true
+	report.write(&written, err, "Run 'prog get -h' for help.")
+	reported := errorJSON{}
+	if decodeErr := json.Unmarshal(written.Bytes(), &reported); decodeErr != nil {
+		t.Fatalf("Report should be JSON instead of %s", written.String())
+	}
+	if reported != (errorJSON{"not_found", "Have not found city Nowhere", "city", exitNotFound}) || strings.Contains(written.String(), "help") {
+		t.Errorf("Unexpected JSON report %s", written.String())
+	}
+
+	written.Reset()
+	quiet = true
+	if status = report.write(&written, util.DataErrorf("Bad cities"), ""); status != exitData || written.Len() != 0 {
+		t.Errorf("Quiet report should write nothing and return %d instead of %d and %s", exitData, status, written.String())
+	}
+}
+
+func TestCommandErrorsExitWithTheirStatus(t *testing.T) {
+	isolate(t)
+	for _, test := range []struct {
+		args           []string
+		expectedStatus int
+	}{
+		{[]string{"forecast"}, exitUsage},
+		{[]string{"get", "-quiet", "-unknown"}, exitUsage},
+		{[]string{"get", "-quiet", "-d", "someday"}, exitInvalidArgument},
+		{[]string{"get", "-quiet", "-c", "Atlantis"}, exitNotFound},
+		{[]string{"import", "-quiet", "/nowhere/temps.json"}, exitIO},
+		{[]string{"get", "-h"}, exitOK},
+	} {
+		if status := Run(test.args); status != test.expectedStatus {
+			t.Errorf("%v should exit with %d instead of %d", test.args, test.expectedStatus, status)
+		}
+	}
+}
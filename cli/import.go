@@ -0,0 +1,182 @@
+package cli
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/ekougs/weather-station/config"
+	"github.com/ekougs/weather-station/util"
+)
+
+var importReportColumns = []string{"city", "read", "added", "replaced", "unchanged", "overwritten", "skipped", "rounded"}
+
+// csvFlags tell how observations are read from CSV records
+type csvFlags struct {
+	timeColumn, cityColumn, tempColumn, city, units, timeZone, delimiter *string
+}
+
+func defineCSVFlags(flagSet *flag.FlagSet) csvFlags {
+	timeColumn := flagSet.String("time-column", "timestamp", "Name or 1-based index of the CSV column of times, like 2006-01-02T15:04:05 with an offset or not, or Unix seconds")
+	cityColumn := flagSet.String("city-column", "city", "Name or 1-based index of the CSV column of city names or codes")
+	tempColumn := flagSet.String("temp-column", "temperature", "Name or 1-based index of the CSV column of temps")
+	city := flagSet.String("city", "", "City of every CSV record, instead of the one of -city-column")
+	units := flagSet.String("units", util.Celsius.Symbol(), "Unit of CSV temps : "+util.TempUnits)
+	timeZone := flagSet.String("tz", "", "Time zone like UTC of CSV times without offset. City time zone by default")
+	delimiter := flagSet.String("delimiter", ",", "Field delimiter of CSV records, like ; or \\t")
+	return csvFlags{timeColumn, cityColumn, tempColumn, city, units, timeZone, delimiter}
+}
+
+func (flags csvFlags) mapping() (util.CSVMapping, error) {
+	mapping := util.CSVMapping{TimeColumn: *flags.timeColumn, CityColumn: *flags.cityColumn, TempColumn: *flags.tempColumn, City: *flags.city}
+	var err error
+	if mapping.Unit, err = util.ParseTempUnit(*flags.units); err != nil {
+		return mapping, err
+	}
+	if *flags.timeZone != "" {
+		if mapping.Location, err = time.LoadLocation(*flags.timeZone); err != nil {
+			return mapping, util.InvalidArgumentErrorf("tz", "Unknown time zone '%s'", *flags.timeZone)
+		}
+	}
+	delimiter := strings.ReplaceAll(*flags.delimiter, `\t`, "\t")
+	if utf8.RuneCountInString(delimiter) != 1 {
+		return mapping, util.InvalidArgumentErrorf("delimiter", "Delimiter '%s' should be a single character", *flags.delimiter)
+	}
+	mapping.Comma, _ = utf8.DecodeRuneInString(delimiter)
+	return mapping, nil
+}
+
+func setUpImport(flagSet *flag.FlagSet) runner {
+	format := flagSet.String("format", "auto", "Format of the imported file : json, csv or auto to read csv files by their .csv extension and json otherwise")
+	csvFlags := defineCSVFlags(flagSet)
+	conflict := flagSet.String("on-conflict", util.ConflictSkip.String(), "What to do with CSV observations different from stored ones : "+util.ConflictPolicies+". Generated temps are always replaced")
+	dryRun := flagSet.Bool("dry-run", false, "Report what a CSV import would do without storing anything")
+	outputFlags := defineOutputFlags(flagSet, tableOutput)
+	return func(settings config.Config, args []string) error {
+		if len(args) != 1 {
+			return usageErrorf("Expecting the file to import, - for standard input")
+		}
+		isCSV := strings.EqualFold(filepath.Ext(args[0]), ".csv")
+		switch strings.ToLower(*format) {
+		case "json":
+			isCSV = false
+		case "csv":
+			isCSV = true
+		case "auto":
+		default:
+			return util.InvalidArgumentErrorf("format", "Unknown import format '%s', expecting json, csv or auto", *format)
+		}
+		app, err := newApplication(settings)
+		if err != nil {
+			return err
+		}
+		input := os.Stdin
+		if args[0] != "-" {
+			input, err = os.Open(args[0])
+			if err != nil {
+				return err
+			}
+			defer input.Close()
+		}
+		if !isCSV {
+			return importTemps(app.dataUtils, input)
+		}
+
+		mapping, err := csvFlags.mapping()
+		if err != nil {
+			return err
+		}
+		policy, err := util.ParseConflictPolicy(*conflict)
+		if err != nil {
+			return err
+		}
+		output, err := outputFlags.resolve()
+		if err != nil {
+			return err
+		}
+		observations, err := app.dataUtils.ReadObservationsCSV(input, mapping)
+		if err != nil {
+			return err
+		}
+		reports, err := importObservations(app.dataUtils, observations, policy, *dryRun)
+		if err != nil {
+			return err
+		}
+		return output.print(os.Stdout, reports, importReportRecords(reports, *dryRun))
+	}
+}
+
+// importTemps stores temps of a JSON document printed by export, keeping
+// already stored ones
+func importTemps(dataUtils util.DataUtils, input io.Reader) error {
+	imported := make(map[string][]util.Temp)
+	if err := json.NewDecoder(input).Decode(&imported); err != nil {
+		return util.InvalidArgumentErrorf("file", "Cannot read temps to import: %s", err)
+	}
+	cityNames := make([]string, 0, len(imported))
+	for cityName := range imported {
+		cityNames = append(cityNames, cityName)
+	}
+	sort.Strings(cityNames)
+	for _, cityName := range cityNames {
+		cityCode, err := dataUtils.GetCityCode(cityName)
+		if err != nil {
+			return err
+		}
+		added, err := dataUtils.ImportTemps(cityCode, imported[cityName])
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Imported %d of %d temps for %s\n", added, len(imported[cityName]), cityCode)
+	}
+	return nil
+}
+
+// importObservations stores observations of every city once a dry run of all
+// of them succeeded, so that a conflict fails the import before anything is
+// stored
+func importObservations(dataUtils util.DataUtils, observations map[string][]util.Temp, policy util.ConflictPolicy, dryRun bool) ([]util.ImportReport, error) {
+	cityCodes := make([]string, 0, len(observations))
+	for cityCode := range observations {
+		cityCodes = append(cityCodes, cityCode)
+	}
+	sort.Strings(cityCodes)
+	reports := make([]util.ImportReport, 0, len(cityCodes))
+	for _, cityCode := range cityCodes {
+		report, err := dataUtils.ImportObservations(cityCode, observations[cityCode], policy, true)
+		if err != nil {
+			return nil, err
+		}
+		reports = append(reports, report)
+	}
+	if dryRun {
+		return reports, nil
+	}
+	for index, cityCode := range cityCodes {
+		report, err := dataUtils.ImportObservations(cityCode, observations[cityCode], policy, false)
+		if err != nil {
+			return nil, err
+		}
+		reports[index] = report
+	}
+	return reports, nil
+}
+
+func importReportRecords(reports []util.ImportReport, dryRun bool) records {
+	rows := make([][]interface{}, 0, len(reports))
+	for _, report := range reports {
+		rows = append(rows, []interface{}{report.City, report.Read, report.Added, report.Replaced, report.Unchanged, report.Overwritten, report.Skipped, report.Rounded})
+	}
+	summary := ""
+	if dryRun {
+		summary = "Dry run, nothing stored"
+	}
+	return records{importReportColumns, rows, summary, nil, nil}
+}
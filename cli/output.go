@@ -0,0 +1,357 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/ekougs/weather-station/chart"
+	"github.com/ekougs/weather-station/util"
+)
+
+// outputFormat tells how printed data are formatted
+type outputFormat int
+
+const (
+	jsonOutput outputFormat = iota
+	tableOutput
+	csvOutput
+	jsonLinesOutput
+	yamlOutput
+	chartOutput
+	sparklineOutput
+)
+
+var outputFormatNames = []string{"json", "table", "csv", "jsonl", "yaml", "chart", "sparkline"}
+
+// chartHeight is the number of lines of charts above their axes
+const chartHeight = 12
+
+// outputFormats lists names of output formats for help messages
+var outputFormats = strings.Join(outputFormatNames, ", ")
+
+func (format outputFormat) String() string {
+	return outputFormatNames[format]
+}
+
+func parseOutputFormat(name string) (outputFormat, error) {
+	for format, formatName := range outputFormatNames {
+		if strings.EqualFold(strings.TrimSpace(name), formatName) {
+			return outputFormat(format), nil
+		}
+	}
+	return jsonOutput, util.InvalidArgumentErrorf("output", "Unknown output format '%s', expecting one of %s", name, outputFormats)
+}
+
+// output tells how data are printed
+// json prints whole documents unless columns are selected, chart and
+// sparkline draw series as wide as the terminal, other formats print rows of
+// the selected columns, all by default
+type output struct {
+	format  outputFormat
+	columns []string
+	header  bool
+	width   int
+}
+
+var defaultOutput = output{jsonOutput, nil, true, defaultTerminalWidth}
+
+// outputFlags tell how data are printed
+type outputFlags struct {
+	format, columns *string
+	noHeader        *bool
+}
+
+func defineOutputFlags(flagSet *flag.FlagSet, defaultFormat outputFormat) outputFlags {
+	format := flagSet.String("o", defaultFormat.String(), "Output format : "+outputFormats)
+	columns := flagSet.String("columns", "", "Comma separated columns to print, all by default. json prints rows instead of the whole document when set")
+	noHeader := flagSet.Bool("no-header", false, "Omit the header of table and csv formats")
+	return outputFlags{format, columns, noHeader}
+}
+
+func (flags outputFlags) resolve() (output, error) {
+	format, err := parseOutputFormat(*flags.format)
+	if err != nil {
+		return defaultOutput, err
+	}
+	var columns []string
+	for _, column := range strings.Split(*flags.columns, ",") {
+		if column = strings.TrimSpace(column); column != "" {
+			columns = append(columns, strings.ToLower(column))
+		}
+	}
+	return output{format, columns, !*flags.noHeader, terminalWidth()}, nil
+}
+
+// records are data as rows of named columns
+// summary is printed under tables, stats are rows of statsColumns printed
+// after the others by jsonl and yaml and series are the ones charted. CSV
+// prints rows only, all of them having the columns of the header
+type records struct {
+	columns []string
+	rows    [][]interface{}
+	summary string
+	stats   [][]interface{}
+	series  []chart.Series
+}
+
+var tempColumns = []string{"city", "time", "utc_offset", "temp", "unit"}
+var statsColumns = []string{"city", "min", "max", "average"}
+var cityColumns = []string{"iata_code", "name"}
+
+func tempRecords(temps []util.CityTemp) records {
+	rows := make([][]interface{}, 0, len(temps))
+	for _, temp := range temps {
+		rows = append(rows, []interface{}{temp.City, timeValue(temp.Time), temp.UTCOffset, temp.Temp, temp.Unit.Symbol()})
+	}
+	return records{tempColumns, rows, "", nil, nil}
+}
+
+func cityTempsRecords(city string, cityTemps util.CityTemps) records {
+	tempsRecords := tempRecords(cityTemps.Temps)
+	tempsRecords.summary = fmt.Sprintf("%s  Min %d  Max %d  Average %d", city, cityTemps.Min, cityTemps.Max, cityTemps.Average)
+	tempsRecords.stats = [][]interface{}{{city, cityTemps.Min, cityTemps.Max, cityTemps.Average}}
+	tempsRecords.series = []chart.Series{chart.FromCityTemps(city, cityTemps)}
+	return tempsRecords
+}
+
+// mergeRecords returns records with rows, summaries, stats and series of all
+// records which have the same columns
+func mergeRecords(allRecords []records) records {
+	merged := records{columns: allRecords[0].columns}
+	summaries := make([]string, 0, len(allRecords))
+	for _, data := range allRecords {
+		merged.rows = append(merged.rows, data.rows...)
+		merged.stats = append(merged.stats, data.stats...)
+		merged.series = append(merged.series, data.series...)
+		if data.summary != "" {
+			summaries = append(summaries, data.summary)
+		}
+	}
+	merged.summary = strings.Join(summaries, "\n")
+	return merged
+}
+
+func cityRecords(cities util.Cities) records {
+	rows := make([][]interface{}, 0, len(cities))
+	for _, city := range cities {
+		rows = append(rows, []interface{}{city.Code, city.Name})
+	}
+	return records{cityColumns, rows, "", nil, nil}
+}
+
+// timeValue renders times like JSON documents, as Unix seconds in
+// EpochLocation
+func timeValue(tempTime util.TempTime) interface{} {
+	if time.Time(tempTime).Location() == util.EpochLocation {
+		return time.Time(tempTime).Unix()
+	}
+	return tempTime.Format(util.TimeFormat)
+}
+
+// formatValue formats numbers the same way whatever the locale
+func formatValue(value interface{}) string {
+	switch typedValue := value.(type) {
+	case int:
+		return strconv.Itoa(typedValue)
+	case int64:
+		return strconv.FormatInt(typedValue, 10)
+	default:
+		return fmt.Sprint(typedValue)
+	}
+}
+
+// selectColumns returns records with columns in the given order, all if none
+func (data records) selectColumns(columns []string) (records, error) {
+	if len(columns) == 0 {
+		return data, nil
+	}
+	indexes := make([]int, 0, len(columns))
+	for _, column := range columns {
+		index := indexOf(data.columns, column)
+		if index < 0 {
+			return data, util.InvalidArgumentErrorf("columns", "Unknown column '%s', expecting some of %s", column, strings.Join(data.columns, ", "))
+		}
+		indexes = append(indexes, index)
+	}
+	rows := make([][]interface{}, 0, len(data.rows))
+	for _, row := range data.rows {
+		selected := make([]interface{}, 0, len(indexes))
+		for _, index := range indexes {
+			selected = append(selected, row[index])
+		}
+		rows = append(rows, selected)
+	}
+	return records{columns, rows, data.summary, data.stats, data.series}, nil
+}
+
+func indexOf(values []string, value string) int {
+	for index, candidate := range values {
+		if candidate == value {
+			return index
+		}
+	}
+	return -1
+}
+
+// print writes document as JSON or data in the output format
+func (output output) print(writer io.Writer, document interface{}, data records) error {
+	switch {
+	case output.format == jsonOutput && len(output.columns) == 0:
+		return printJSON(writer, document)
+	case output.format == chartOutput || output.format == sparklineOutput:
+		if len(data.series) == 0 {
+			return util.InvalidArgumentErrorf("output", "Only temps of a period can be drawn as %s, use range command", output.format)
+		}
+		if output.format == chartOutput {
+			return chart.Text(writer, data.series, output.width, chartHeight)
+		}
+		return chart.Sparklines(writer, data.series, output.width)
+	}
+	data, err := data.selectColumns(output.columns)
+	if err != nil {
+		return err
+	}
+	switch output.format {
+	case tableOutput:
+		return output.printTable(writer, data)
+	case csvOutput:
+		return output.printCSV(writer, data)
+	case jsonLinesOutput:
+		return printJSONLines(writer, data)
+	case yamlOutput:
+		return printYAML(writer, data)
+	default:
+		objects := make([]object, 0, len(data.rows))
+		for _, row := range data.rows {
+			objects = append(objects, object{data.columns, row})
+		}
+		return printJSON(writer, objects)
+	}
+}
+
+func (output output) printTable(writer io.Writer, data records) error {
+	tabWriter := tabwriter.NewWriter(writer, 0, 4, 2, ' ', 0)
+	if output.header {
+		fmt.Fprintln(tabWriter, strings.ToUpper(strings.Join(data.columns, "\t")))
+	}
+	for _, row := range data.rows {
+		values := make([]string, 0, len(row))
+		for _, value := range row {
+			values = append(values, formatValue(value))
+		}
+		fmt.Fprintln(tabWriter, strings.Join(values, "\t"))
+	}
+	if err := tabWriter.Flush(); err != nil {
+		return err
+	}
+	if data.summary != "" {
+		_, err := fmt.Fprintf(writer, "\n%s\n", data.summary)
+		return err
+	}
+	return nil
+}
+
+func (output output) printCSV(writer io.Writer, data records) error {
+	csvWriter := csv.NewWriter(writer)
+	if output.header {
+		csvWriter.Write(data.columns)
+	}
+	for _, row := range data.rows {
+		values := make([]string, 0, len(row))
+		for _, value := range row {
+			values = append(values, formatValue(value))
+		}
+		csvWriter.Write(values)
+	}
+	csvWriter.Flush()
+	return csvWriter.Error()
+}
+
+// printJSONLines writes an object per row then per stats, like the lines of
+// streamed temps
+func printJSONLines(writer io.Writer, data records) error {
+	encoder := json.NewEncoder(writer)
+	for _, row := range data.rows {
+		if err := encoder.Encode(object{data.columns, row}); err != nil {
+			return err
+		}
+	}
+	for _, stats := range data.stats {
+		if err := encoder.Encode(object{statsColumns, stats}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// printYAML writes rows as a sequence of mappings, then stats as a second
+// document
+func printYAML(writer io.Writer, data records) error {
+	if err := printYAMLSequence(writer, data.columns, data.rows); err != nil || len(data.stats) == 0 {
+		return err
+	}
+	if _, err := fmt.Fprintln(writer, "---"); err != nil {
+		return err
+	}
+	return printYAMLSequence(writer, statsColumns, data.stats)
+}
+
+// printYAMLSequence writes rows as a sequence of mappings, strings being
+// double quoted like JSON ones which YAML reads the same way
+func printYAMLSequence(writer io.Writer, columns []string, rows [][]interface{}) error {
+	if len(rows) == 0 {
+		_, err := fmt.Fprintln(writer, "[]")
+		return err
+	}
+	for _, row := range rows {
+		for index, value := range row {
+			prefix := "  "
+			if index == 0 {
+				prefix = "- "
+			}
+			jsonValue, err := json.Marshal(value)
+			if err != nil {
+				return err
+			}
+			if _, err = fmt.Fprintf(writer, "%s%s: %s\n", prefix, columns[index], jsonValue); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// object is a row rendered as a JSON object keeping the columns order
+type object struct {
+	columns []string
+	values  []interface{}
+}
+
+// MarshalJSON renders columns in order
+func (row object) MarshalJSON() ([]byte, error) {
+	var buffer bytes.Buffer
+	buffer.WriteByte('{')
+	for index, column := range row.columns {
+		if index > 0 {
+			buffer.WriteByte(',')
+		}
+		key, _ := json.Marshal(column)
+		value, err := json.Marshal(row.values[index])
+		if err != nil {
+			return nil, err
+		}
+		buffer.Write(key)
+		buffer.WriteByte(':')
+		buffer.Write(value)
+	}
+	buffer.WriteByte('}')
+	return buffer.Bytes(), nil
+}
@@ -0,0 +1,84 @@
+package cli
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/ekougs/weather-station/util"
+)
+
+var testRecords = records{[]string{"city", "time", "temp"},
+	[][]interface{}{{"PAR", "2015-04-02T17:00:00+02:00", 12}, {"NYC", "2015-04-02T11:00:00-04:00", -3}}, "2 cities", nil, nil}
+
+func printed(output output, t *testing.T) string {
+	t.Helper()
+	var written bytes.Buffer
+	if err := output.print(&written, nil, testRecords); err != nil {
+		t.Fatal(err)
+	}
+	return written.String()
+}
+
+func TestRowsPrintedInOutputFormats(t *testing.T) {
+	for _, test := range []struct {
+		output   output
+		expected string
+	}{
+		{output{csvOutput, nil, true, 80}, "city,time,temp\nPAR,2015-04-02T17:00:00+02:00,12\nNYC,2015-04-02T11:00:00-04:00,-3\n"},
+		{output{csvOutput, nil, false, 80}, "PAR,2015-04-02T17:00:00+02:00,12\nNYC,2015-04-02T11:00:00-04:00,-3\n"},
+		{output{tableOutput, nil, true, 80}, "CITY  TIME                       TEMP\nPAR   2015-04-02T17:00:00+02:00  12\nNYC   2015-04-02T11:00:00-04:00  -3\n\n2 cities\n"},
+		{output{jsonLinesOutput, nil, true, 80}, `{"city":"PAR","time":"2015-04-02T17:00:00+02:00","temp":12}` + "\n" + `{"city":"NYC","time":"2015-04-02T11:00:00-04:00","temp":-3}` + "\n"},
+	} {
+		if written := printed(test.output, t); written != test.expected {
+			t.Errorf("%s output should be\n%s\ninstead of\n%s", test.output.format, test.expected, written)
+		}
+	}
+}
+
+func TestSelectedColumnsArePrintedInOrder(t *testing.T) {
+	expected := "TEMP  CITY\n12    PAR\n-3    NYC\n\n2 cities\n"
+	if written := printed(output{tableOutput, []string{"temp", "city"}, true, 80}, t); written != expected {
+		t.Errorf("Expecting\n%s\ninstead of\n%s", expected, written)
+	}
+	expected = `[
+    {
+        "temp": 12
+    },
+    {
+        "temp": -3
+    }
+]
+`
+	if written := printed(output{jsonOutput, []string{"temp"}, true, 80}, t); written != expected {
+		t.Errorf("json with columns should print rows\n%s\ninstead of\n%s", expected, written)
+	}
+
+	var written bytes.Buffer
+	err := output{csvOutput, []string{"humidity"}, true, 80}.print(&written, nil, testRecords)
+	if util.GetErrorKind(err) != util.InvalidArgumentError || util.GetErrorField(err) != "columns" {
+		t.Errorf("Unknown column should be an invalid columns instead of '%v'", err)
+	}
+}
+
+func TestPeriodStatsPrintedAfterTemps(t *testing.T) {
+	data := mergeRecords([]records{cityTempsRecords("PAR", util.CityTemps{Min: 3, Max: 12, Average: 8}),
+		cityTempsRecords("NYC", util.CityTemps{Min: -3, Max: 5, Average: 1})})
+	for _, test := range []struct {
+		output   output
+		expected string
+	}{
+		{output{tableOutput, nil, true, 80}, "CITY  TIME  UTC_OFFSET  TEMP  UNIT\n\nPAR  Min 3  Max 12  Average 8\nNYC  Min -3  Max 5  Average 1\n"},
+		{output{jsonLinesOutput, nil, true, 80}, `{"city":"PAR","min":3,"max":12,"average":8}` + "\n" + `{"city":"NYC","min":-3,"max":5,"average":1}` + "\n"},
+		{output{yamlOutput, nil, true, 80}, "[]\n---\n- city: \"PAR\"\n  min: 3\n  max: 12\n  average: 8\n- city: \"NYC\"\n  min: -3\n  max: 5\n  average: 1\n"},
+		// Records of CSV all have the columns of the header
+		{output{csvOutput, nil, true, 80}, "city,time,utc_offset,temp,unit\n"},
+	} {
+		var written bytes.Buffer
+		if err := test.output.print(&written, nil, data); err != nil {
+			t.Fatal(err)
+		}
+		if written.String() != test.expected {
+			t.Errorf("%s output should be\n%s\ninstead of\n%s", test.output.format, test.expected, written.String())
+		}
+	}
+}
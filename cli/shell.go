@@ -0,0 +1,426 @@
+package cli
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/ekougs/weather-station/config"
+	"github.com/ekougs/weather-station/util"
+
+	"golang.org/x/term"
+)
+
+// historyFile is the file of the data directory keeping lines of the shell
+const historyFile = "shell_history"
+
+// maxHistory is the number of lines of the shell kept in history
+const maxHistory = 500
+
+// minutesTimeRegexp matches local times without seconds, accepted by the shell
+var minutesTimeRegexp = regexp.MustCompile(`T\d{2}:\d{2}$`)
+
+// shellSession is the state of the shell kept between commands, with
+// components loaded once
+type shellSession struct {
+	app           application
+	timeUtils     util.TimeUtils
+	city          string
+	formattedDate string
+	duration      string
+	unit          util.TempUnit
+	output        output
+	writer        io.Writer
+}
+
+// shellCommand is a command of the shell taking the rest of the line as
+// argument. complete returns candidates to complete the argument with
+type shellCommand struct {
+	name, argument, summary string
+	run                     func(session *shellSession, argument string) error
+	complete                func(session *shellSession) []string
+}
+
+var shellCommands []shellCommand
+
+func init() {
+	// Declared in init as help lists shell commands
+	shellCommands = []shellCommand{
+		{"city", "[CITY]", "Set the city of temps, print it without argument", (*shellSession).setCity, (*shellSession).cityNames},
+		{"at", "[DATE]", "Set the date of temps like 2015-04-02T17:00, yesterday or now, print it without argument", (*shellSession).setDate, nil},
+		{"get", "", "Print the temp of the city at the date", (*shellSession).printTemp, nil},
+		{"range", "[DURATION]", "Print the temps of the city for the period like 3M ending at the date, the last one without argument", (*shellSession).printTemps, nil},
+		{"stats", "", "Print min, max and average temps of the city for the last period", (*shellSession).printStats, nil},
+		{"units", "[UNIT]", "Set the unit of temps : " + util.TempUnits, (*shellSession).setUnit, unitNames},
+		{"output", "[FORMAT]", "Set the output format : " + outputFormats, (*shellSession).setOutput, formatNames},
+		{"cities", "", "List handled cities", (*shellSession).printCities, nil},
+		{"help", "", "List commands", (*shellSession).printHelp, nil},
+		{"exit", "", "Leave the shell, like quit, Ctrl-D or Ctrl-C", nil, nil},
+	}
+}
+
+func setUpShell(flagSet *flag.FlagSet) runner {
+	dstPolicyName := defineDSTFlag(flagSet)
+	outputFlags := defineOutputFlags(flagSet, tableOutput)
+	return func(settings config.Config, args []string) error {
+		output, err := outputFlags.resolve()
+		if err != nil {
+			return err
+		}
+		app, err := newApplication(settings)
+		if err != nil {
+			return err
+		}
+		dstPolicy, err := util.ParseDSTPolicy(*dstPolicyName)
+		if err != nil {
+			return err
+		}
+		city, err := app.dataUtils.GetCityCode(settings.DefaultCity)
+		if err != nil {
+			return err
+		}
+		session := &shellSession{app, app.timeUtils.WithDSTPolicy(dstPolicy), city, "now", "", settings.Units, output, os.Stdout}
+		return session.run(settings.DataDir)
+	}
+}
+
+// run reads commands until the end of the standard input. Lines can be
+// edited, recalled from history of previous sessions and completed when
+// the standard input is a terminal
+func (session *shellSession) run(dataDir string) error {
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		scanner := bufio.NewScanner(os.Stdin)
+		for scanner.Scan() {
+			if session.execute(scanner.Text()) {
+				return nil
+			}
+		}
+		return scanner.Err()
+	}
+
+	state, err := term.MakeRaw(fd)
+	if err != nil {
+		return err
+	}
+	defer term.Restore(fd, state)
+	terminal := term.NewTerminal(struct {
+		io.Reader
+		io.Writer
+	}{os.Stdin, os.Stdout}, session.prompt())
+	terminal.AutoCompleteCallback = session.complete
+	// Terminal.History needs golang.org/x/term v0.30 or later
+	terminal.History = loadShellHistory(filepath.Join(dataDir, historyFile))
+	if width, height, err := term.GetSize(fd); err == nil && width > 0 {
+		terminal.SetSize(width, height)
+		session.output.width = width
+	}
+	session.writer = terminal
+	fmt.Fprintln(terminal, "Type help for commands, Tab completes commands and cities")
+	for {
+		line, err := terminal.ReadLine()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if session.execute(line) {
+			return nil
+		}
+		terminal.SetPrompt(session.prompt())
+	}
+}
+
+func (session *shellSession) prompt() string {
+	return session.city + " " + session.formattedDate + "> "
+}
+
+// execute runs the command of line and returns whether the shell should be
+// left. Errors are printed
+func (session *shellSession) execute(line string) bool {
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return false
+	}
+	if fields[0] == "exit" || fields[0] == "quit" {
+		return true
+	}
+	command, found := getShellCommand(fields[0])
+	if !found {
+		fmt.Fprintf(session.writer, "Unknown command '%s', type help for commands\n", fields[0])
+		return false
+	}
+	argument := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(line), fields[0]))
+	if err := command.run(session, argument); err != nil {
+		fmt.Fprintln(session.writer, err)
+	}
+	return false
+}
+
+func getShellCommand(name string) (shellCommand, bool) {
+	for _, command := range shellCommands {
+		if command.name == name && command.run != nil {
+			return command, true
+		}
+	}
+	return shellCommand{}, false
+}
+
+// complete completes the command name or its argument before the cursor
+// with the longest prefix shared by candidates when Tab is pressed
+func (session *shellSession) complete(line string, pos int, key rune) (string, int, bool) {
+	if key != '\t' {
+		return "", 0, false
+	}
+	beforeCursor := line[:pos]
+	var candidates []string
+	prefix, suffix := beforeCursor, ""
+	if nameEnd := strings.Index(beforeCursor, " "); nameEnd < 0 {
+		for _, command := range shellCommands {
+			candidates = append(candidates, command.name)
+		}
+		suffix = " "
+	} else {
+		command, found := getShellCommand(beforeCursor[:nameEnd])
+		if !found || command.complete == nil {
+			return "", 0, false
+		}
+		candidates = command.complete(session)
+		prefix = strings.TrimLeft(beforeCursor[nameEnd:], " ")
+	}
+
+	var matches []string
+	for _, candidate := range candidates {
+		if strings.HasPrefix(strings.ToLower(candidate), strings.ToLower(prefix)) {
+			matches = append(matches, candidate)
+		}
+	}
+	if len(matches) == 0 {
+		return "", 0, false
+	}
+	completion := commonPrefix(matches)
+	if len(matches) == 1 {
+		completion += suffix
+	}
+	start := pos - len(prefix)
+	return line[:start] + completion + line[pos:], start + len(completion), true
+}
+
+// commonPrefix returns the longest prefix of values, ignoring case, with the
+// case of the first value
+func commonPrefix(values []string) string {
+	prefix := values[0]
+	for _, value := range values[1:] {
+		length := 0
+		for length < len(prefix) && length < len(value) && strings.EqualFold(prefix[length:length+1], value[length:length+1]) {
+			length++
+		}
+		prefix = prefix[:length]
+	}
+	return prefix
+}
+
+func (session *shellSession) cityNames() []string {
+	cities, err := session.app.dataUtils.GetCities()
+	if err != nil {
+		return nil
+	}
+	names := make([]string, 0, 2*len(cities))
+	for _, city := range cities {
+		names = append(names, city.Code, city.Name)
+	}
+	return names
+}
+
+func unitNames(*shellSession) []string {
+	return strings.Split(util.TempUnits, ", ")
+}
+
+func formatNames(*shellSession) []string {
+	return outputFormatNames
+}
+
+func (session *shellSession) setCity(argument string) error {
+	if argument != "" {
+		city, err := session.app.dataUtils.GetCityCode(argument)
+		if err != nil {
+			return err
+		}
+		session.city = city
+	}
+	fmt.Fprintln(session.writer, session.city)
+	return nil
+}
+
+func (session *shellSession) setDate(argument string) error {
+	if argument != "" {
+		if minutesTimeRegexp.MatchString(argument) {
+			argument += ":00"
+		}
+		if _, err := session.timeUtils.GetTime(argument, session.city); err != nil {
+			return err
+		}
+		session.formattedDate = argument
+	}
+	date, err := session.timeUtils.GetTime(session.formattedDate, session.city)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(session.writer, date.Format(util.TimeFormat))
+	return nil
+}
+
+// handler returns a handler for the city at the date, read again so that
+// relative dates like now follow the time
+func (session *shellSession) handler(duration string) (InstructionHandler, error) {
+	date, err := session.timeUtils.GetTime(session.formattedDate, session.city)
+	if err != nil {
+		return InstructionHandler{}, err
+	}
+	return NewCLIInstructionHandler([]string{session.city}, date, duration, nil).WithUnit(session.unit).withOutput(session.output), nil
+}
+
+func (session *shellSession) printTemp(string) error {
+	handler, err := session.handler("")
+	if err != nil {
+		return err
+	}
+	return handler.writeResponse(session.writer, session.app.tempProvider, session.timeUtils)
+}
+
+func (session *shellSession) printTemps(argument string) error {
+	duration := argument
+	if duration == "" {
+		duration = session.duration
+	}
+	if duration == "" {
+		return util.InvalidArgumentErrorf("duration", "Expecting a period like 1Y3M2D, 1Y2M, 3M2D or 3D")
+	}
+	handler, err := session.handler(duration)
+	if err != nil {
+		return err
+	}
+	if err = handler.writeResponse(session.writer, session.app.tempProvider, session.timeUtils); err != nil {
+		return err
+	}
+	session.duration = duration
+	return nil
+}
+
+func (session *shellSession) printStats(string) error {
+	if session.duration == "" {
+		return util.InvalidArgumentErrorf("duration", "No period yet, use range command first")
+	}
+	handler, err := session.handler(session.duration)
+	if err != nil {
+		return err
+	}
+	cityTemps, err := handler.getTemps(session.app.tempProvider, session.timeUtils, session.city)
+	if err != nil {
+		return err
+	}
+	stats := struct {
+		City              string
+		Duration          string
+		Min, Max, Average int
+		Temps             int
+	}{session.city, session.duration, cityTemps.Min, cityTemps.Max, cityTemps.Average, len(cityTemps.Temps)}
+	statsRecords := records{[]string{"city", "duration", "min", "max", "average", "temps"},
+		[][]interface{}{{stats.City, stats.Duration, stats.Min, stats.Max, stats.Average, stats.Temps}}, "", nil, nil}
+	return session.output.print(session.writer, stats, statsRecords)
+}
+
+func (session *shellSession) setUnit(argument string) error {
+	if argument != "" {
+		unit, err := util.ParseTempUnit(argument)
+		if err != nil {
+			return err
+		}
+		session.unit = unit
+	}
+	fmt.Fprintln(session.writer, session.unit)
+	return nil
+}
+
+func (session *shellSession) setOutput(argument string) error {
+	if argument != "" {
+		format, err := parseOutputFormat(argument)
+		if err != nil {
+			return err
+		}
+		session.output.format = format
+	}
+	fmt.Fprintln(session.writer, session.output.format)
+	return nil
+}
+
+func (session *shellSession) printCities(string) error {
+	cities, err := session.app.dataUtils.GetCities()
+	if err != nil {
+		return err
+	}
+	return session.output.print(session.writer, cities, cityRecords(cities))
+}
+
+func (session *shellSession) printHelp(string) error {
+	for _, command := range shellCommands {
+		fmt.Fprintf(session.writer, "  %-20s %s\n", command.name+" "+command.argument, command.summary)
+	}
+	return nil
+}
+
+// shellHistory keeps lines of the shell in a file so that they can be
+// recalled in next sessions
+type shellHistory struct {
+	file  string
+	lines []string
+}
+
+// loadShellHistory reads the last lines kept in file, which may not exist
+func loadShellHistory(file string) *shellHistory {
+	history := &shellHistory{file: file}
+	content, err := os.ReadFile(file)
+	if err != nil || len(content) == 0 {
+		return history
+	}
+	history.lines = strings.Split(strings.TrimRight(string(content), "\n"), "\n")
+	if len(history.lines) > maxHistory {
+		history.lines = history.lines[len(history.lines)-maxHistory:]
+		os.WriteFile(file, []byte(strings.Join(history.lines, "\n")+"\n"), 0600)
+	}
+	return history
+}
+
+// Add keeps entry, failing silently to write it as history is a convenience
+func (history *shellHistory) Add(entry string) {
+	history.lines = append(history.lines, entry)
+	if len(history.lines) > maxHistory {
+		history.lines = history.lines[1:]
+	}
+	// The data directory does not exist until something is stored
+	if err := os.MkdirAll(filepath.Dir(history.file), 0755); err != nil {
+		return
+	}
+	historyWriter, err := os.OpenFile(history.file, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return
+	}
+	defer historyWriter.Close()
+	fmt.Fprintln(historyWriter, entry)
+}
+
+// Len returns the number of kept lines
+func (history *shellHistory) Len() int {
+	return len(history.lines)
+}
+
+// At returns the line kept at index, 0 being the most recent one
+func (history *shellHistory) At(index int) string {
+	return history.lines[len(history.lines)-1-index]
+}
@@ -0,0 +1,69 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCommonPrefixIgnoresCase(t *testing.T) {
+	for expected, values := range map[string][]string{
+		"New":   {"New York", "newark"},
+		"range": {"range"},
+		"":      {"Paris", "Dakar"},
+	} {
+		if prefix := commonPrefix(values); prefix != expected {
+			t.Errorf("Common prefix of %v should be '%s' instead of '%s'", values, expected, prefix)
+		}
+	}
+}
+
+func TestShellCompletesCommandsAndArguments(t *testing.T) {
+	session := &shellSession{}
+	for _, test := range []struct {
+		line, expected string
+		completed      bool
+	}{
+		{"ra", "range ", true},
+		{"c", "cit", true},
+		{"units f", "units fahrenheit", true},
+		{"output js", "output json", true},
+		{"get x", "", false},
+		{"zz", "", false},
+	} {
+		line, pos, completed := session.complete(test.line, len(test.line), '\t')
+		if completed != test.completed || line != test.expected || (completed && pos != len(line)) {
+			t.Errorf("'%s' should be completed as '%s' instead of '%s' at %d", test.line, test.expected, line, pos)
+		}
+	}
+	if _, _, completed := session.complete("ra", 2, 'a'); completed {
+		t.Error("Only Tab should complete")
+	}
+}
+
+func TestShellHistoryKeepsLastLines(t *testing.T) {
+	file := filepath.Join(t.TempDir(), historyFile)
+	var lines []string
+	for index := 0; index < maxHistory+10; index++ {
+		lines = append(lines, fmt.Sprintf("at 2015-04-02T%02d:00", index%24))
+	}
+	os.WriteFile(file, []byte(strings.Join(lines, "\n")+"\n"), 0600)
+
+	history := loadShellHistory(file)
+	if history.Len() != maxHistory || history.At(0) != lines[len(lines)-1] {
+		t.Errorf("Expecting %d lines, the most recent first, instead of %d starting with %s", maxHistory, history.Len(), history.At(0))
+	}
+	history.Add("range 3M")
+	if reloaded := loadShellHistory(file); reloaded.Len() != maxHistory || reloaded.At(0) != "range 3M" {
+		t.Errorf("Added line should be kept for next sessions instead of %s", reloaded.At(0))
+	}
+
+	// The data directory does not exist on first run
+	file = filepath.Join(t.TempDir(), "weather-station", historyFile)
+	loadShellHistory(file).Add("stats")
+	if reloaded := loadShellHistory(file); reloaded.Len() != 1 || reloaded.At(0) != "stats" {
+		t.Errorf("History should be written in a new data directory instead of %d lines", reloaded.Len())
+	}
+}
@@ -0,0 +1,24 @@
+package cli
+
+import (
+	"os"
+	"strconv"
+
+	"golang.org/x/term"
+)
+
+// defaultTerminalWidth is used when the terminal width cannot be known, like
+// when the output is redirected
+const defaultTerminalWidth = 80
+
+// terminalWidth returns the COLUMNS environment variable if set, else the
+// width of the terminal of the standard output
+func terminalWidth() int {
+	if columns, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && columns > 0 {
+		return columns
+	}
+	if width, _, err := term.GetSize(int(os.Stdout.Fd())); err == nil && width > 0 {
+		return width
+	}
+	return defaultTerminalWidth
+}
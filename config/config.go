@@ -0,0 +1,299 @@
+package config
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/ekougs/weather-station/util"
+)
+
+// EnvPrefix starts the name of environment variables overriding settings,
+// like WEATHER_DEFAULT_CITY for default_city
+const EnvPrefix = "WEATHER_"
+
+// FileEnv names the environment variable holding the config file path
+const FileEnv = EnvPrefix + "CONFIG"
+
+// FileFlag names the flag holding the config file path
+const FileFlag = "config"
+
+// Keys of settings in config file
+const (
+	DataDirKey     = "data_dir"
+	CitiesFileKey  = "cities_file"
+	ListenKey      = "listen"
+	DefaultCityKey = "default_city"
+	SeedKey        = "seed"
+	UnitsKey       = "units"
+	ModelKey       = "model"
+	AdminSecretKey = "admin_secret"
+)
+
+const (
+	sourceDefault = "default"
+	sourceFile    = "file"
+	sourceEnv     = "env"
+	sourceFlag    = "flag"
+)
+
+// Config is the effective configuration of the application
+// Every setting comes from a flag, else a WEATHER_* environment variable,
+// else the config file, else its default value
+type Config struct {
+	DataDir     string
+	CitiesFile  string
+	Listen      string
+	DefaultCity string
+	// Seed 0 means a seed depending on the launch time
+	Seed        int64
+	Units       util.TempUnit
+	Model       util.GeneratorModel
+	AdminSecret string
+	file        string
+	sources     map[string]string
+}
+
+// setting is a key of the config file, also available as environment
+// variable and as flag if flagName is not empty
+type setting struct {
+	key, flagName, usage string
+	get                  func(config Config) string
+	set                  func(config *Config, value string) error
+}
+
+var settings = []setting{
+	{DataDirKey, "data-dir", "Directory where generated temps are stored",
+		func(config Config) string { return config.DataDir },
+		func(config *Config, value string) error { config.DataDir = value; return nil }},
+	{CitiesFileKey, "cities-file", "Cities JSON file. Cities embedded in the executable by default",
+		func(config Config) string { return config.CitiesFile },
+		func(config *Config, value string) error { config.CitiesFile = value; return nil }},
+	{ListenKey, "listen", "Address like 127.0.0.1:8080 or :1987 the server listens on in server mode",
+		func(config Config) string { return config.Listen },
+		func(config *Config, value string) error { config.Listen = value; return nil }},
+	{DefaultCityKey, "c", "IATA code or name for city, listed below. Comma separated ones print several cities",
+		func(config Config) string { return config.DefaultCity },
+		func(config *Config, value string) error { config.DefaultCity = value; return nil }},
+	{SeedKey, "seed", "Seed of generated temps, same seed, city and time always generate the same temp. 0 for a random one",
+		func(config Config) string { return strconv.FormatInt(config.Seed, 10) },
+		func(config *Config, value string) error {
+			seed, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return fmt.Errorf("Seed '%s' should be an integer", value)
+			}
+			config.Seed = seed
+			return nil
+		}},
+	{UnitsKey, "units", "Unit of temps : " + util.TempUnits,
+		func(config Config) string { return config.Units.String() },
+		func(config *Config, value string) (err error) {
+			config.Units, err = util.ParseTempUnit(value)
+			return err
+		}},
+	{ModelKey, "model", "Model generating temps : " + util.GeneratorModels,
+		func(config Config) string { return config.Model.String() },
+		func(config *Config, value string) (err error) {
+			config.Model, err = util.ParseGeneratorModel(value)
+			return err
+		}},
+	{AdminSecretKey, "", "",
+		func(config Config) string { return config.AdminSecret },
+		func(config *Config, value string) error { config.AdminSecret = value; return nil }},
+}
+
+// DefineFlags defines in flagSet the config file flag and a flag for every
+// setting named keys available as flag, all if none, showing defaults values
+// in help
+func DefineFlags(flagSet *flag.FlagSet, defaults Config, keys ...string) {
+	flagSet.String(FileFlag, "", "JSON config file, or TOML one with .toml extension, or YAML one with .yaml or .yml extension. "+DefaultFile()+" if it exists by default, or "+FileEnv+" environment variable")
+	for _, setting := range settings {
+		if setting.flagName != "" && (len(keys) == 0 || contains(keys, setting.key)) {
+			flagSet.String(setting.flagName, setting.get(defaults), setting.usage)
+		}
+	}
+}
+
+func contains(keys []string, key string) bool {
+	for _, candidate := range keys {
+		if candidate == key {
+			return true
+		}
+	}
+	return false
+}
+
+// DefaultFile returns the path of the config file used when none is provided
+func DefaultFile() string {
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		configDir = "."
+	}
+	return filepath.Join(configDir, "weather-station", "config.json")
+}
+
+// DefaultDataDir returns weather-station directory in XDG data home,
+// ~/.local/share by default
+func DefaultDataDir() string {
+	dataHome := os.Getenv("XDG_DATA_HOME")
+	if dataHome == "" {
+		homeDir, err := os.UserHomeDir()
+		if err != nil {
+			return "weather-station"
+		}
+		dataHome = filepath.Join(homeDir, ".local", "share")
+	}
+	return filepath.Join(dataHome, "weather-station")
+}
+
+// Load returns defaults overridden by the config file, then WEATHER_*
+// environment variables, then flags set in flagSet which must be parsed
+func Load(defaults Config, flagSet *flag.FlagSet) (Config, error) {
+	config := defaults
+	config.sources = make(map[string]string, len(settings))
+	for _, setting := range settings {
+		config.sources[setting.key] = sourceDefault
+	}
+
+	setFlags := make(map[string]string)
+	flagSet.Visit(func(setFlag *flag.Flag) {
+		setFlags[setFlag.Name] = setFlag.Value.String()
+	})
+
+	file, required := setFlags[FileFlag], true
+	if file == "" {
+		file = os.Getenv(FileEnv)
+	}
+	if file == "" {
+		file, required = DefaultFile(), false
+	}
+	if err := config.loadFile(file, required); err != nil {
+		return config, err
+	}
+
+	for _, setting := range settings {
+		if value, found := os.LookupEnv(EnvPrefix + envName(setting.key)); found {
+			if err := config.set(setting, value, sourceEnv); err != nil {
+				return config, util.InvalidArgumentErrorf(setting.key, "Invalid %s%s environment variable: %s", EnvPrefix, envName(setting.key), err)
+			}
+		}
+	}
+
+	for _, setting := range settings {
+		if value, found := setFlags[setting.flagName]; found && setting.flagName != "" {
+			if err := config.set(setting, value, sourceFlag); err != nil {
+				return config, util.InvalidArgumentErrorf(setting.key, "Invalid -%s flag: %s", setting.flagName, err)
+			}
+		}
+	}
+
+	return config, nil
+}
+
+// loadFile overrides settings with the ones of the JSON object in file, or
+// of its key = value lines for a .toml file, or of its key: value lines for a
+// .yaml or .yml file
+// A file which is not required may not exist
+func (config *Config) loadFile(file string, required bool) error {
+	fileReader, err := os.Open(file)
+	if os.IsNotExist(err) && !required {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("Cannot read config file: %w", err)
+	}
+	defer fileReader.Close()
+
+	values := make(map[string]interface{})
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".toml":
+		if values, err = decodeTOML(fileReader); err != nil {
+			return util.InvalidArgumentErrorf(FileFlag, "Config file %s is not a flat TOML document: %s", file, err)
+		}
+	case ".yaml", ".yml":
+		if values, err = decodeYAML(fileReader); err != nil {
+			return util.InvalidArgumentErrorf(FileFlag, "Config file %s is not a flat YAML document: %s", file, err)
+		}
+	default:
+		decoder := json.NewDecoder(fileReader)
+		// Keeps seeds which cannot be represented as float64
+		decoder.UseNumber()
+		if err = decoder.Decode(&values); err != nil {
+			return util.InvalidArgumentErrorf(FileFlag, "Config file %s is not a JSON object: %s", file, err)
+		}
+	}
+	config.file = file
+	for key, value := range values {
+		setting, found := getSetting(key)
+		if !found {
+			return util.InvalidArgumentErrorf(FileFlag, "Unknown key '%s' in config file %s", key, file)
+		}
+		if err = config.set(setting, fmt.Sprint(value), sourceFile); err != nil {
+			return util.InvalidArgumentErrorf(key, "Invalid %s in config file %s: %s", key, file, err)
+		}
+	}
+	return nil
+}
+
+// Override returns the config with the setting named key set to value
+// provided by a flag which is not the setting one
+func (config Config) Override(key, value string) (Config, error) {
+	setting, found := getSetting(key)
+	if !found {
+		return config, fmt.Errorf("Unknown setting '%s'", key)
+	}
+	sources := make(map[string]string, len(config.sources))
+	for sourceKey, keySource := range config.sources {
+		sources[sourceKey] = keySource
+	}
+	config.sources = sources
+	err := config.set(setting, value, sourceFlag)
+	return config, err
+}
+
+func (config *Config) set(setting setting, value, source string) error {
+	if err := setting.set(config, value); err != nil {
+		return err
+	}
+	config.sources[setting.key] = source
+	return nil
+}
+
+// Show writes every setting with its effective value and where it comes from
+// Secrets are masked
+func (config Config) Show(writer io.Writer) error {
+	tabWriter := tabwriter.NewWriter(writer, 0, 4, 2, ' ', 0)
+	if config.file != "" {
+		fmt.Fprintf(tabWriter, "# Config file %s\n", config.file)
+	}
+	for _, setting := range settings {
+		value := setting.get(config)
+		if setting.key == AdminSecretKey && value != "" {
+			value = "********"
+		}
+		if setting.key == CitiesFileKey && value == "" {
+			value = "embedded"
+		}
+		fmt.Fprintf(tabWriter, "%s\t%s\t(%s)\n", setting.key, value, config.sources[setting.key])
+	}
+	return tabWriter.Flush()
+}
+
+func getSetting(key string) (setting, bool) {
+	for _, setting := range settings {
+		if setting.key == key {
+			return setting, true
+		}
+	}
+	return setting{}, false
+}
+
+func envName(key string) string {
+	return strings.ToUpper(key)
+}
@@ -0,0 +1,146 @@
+package config
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ekougs/weather-station/util"
+)
+
+var testDefaults = Config{DataDir: "/var/lib/weather", Listen: ":1987", DefaultCity: "DKR"}
+
+func loadWithArgs(args []string, t *testing.T) (Config, error) {
+	// A config file of the user running tests must not be read
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	flagSet := flag.NewFlagSet("test", flag.ContinueOnError)
+	DefineFlags(flagSet, testDefaults)
+	if err := flagSet.Parse(args); err != nil {
+		t.Fatalf("Should not have an error parsing %v '%s'", args, err)
+	}
+	return Load(testDefaults, flagSet)
+}
+
+func writeConfigFile(content string, t *testing.T) string {
+	file := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(file, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+func TestFlagOverridesEnvWhichOverridesFileWhichOverridesDefault(t *testing.T) {
+	file := writeConfigFile(`{"default_city": "PAR", "units": "F", "model": "diurnal", "seed": 9007199254740993}`, t)
+	t.Setenv(EnvPrefix+"UNITS", "celsius")
+	t.Setenv(EnvPrefix+"DEFAULT_CITY", "NYC")
+	config, err := loadWithArgs([]string{"-config", file, "-c", "Paris"}, t)
+	if err != nil {
+		t.Fatalf("Should not have an error '%s'", err)
+	}
+	if config.DefaultCity != "Paris" || config.Units != util.Celsius || config.Model != util.DiurnalModel ||
+		config.Seed != 9007199254740993 || config.Listen != ":1987" {
+		t.Errorf("Unexpected config %+v", config)
+	}
+	if config.CitiesFile != "" {
+		t.Errorf("Cities file %s should be empty to use embedded cities by default", config.CitiesFile)
+	}
+
+	var shown bytes.Buffer
+	config.Show(&shown)
+	for _, expectedLine := range []string{"default_city  Paris", "(flag)", "units         celsius", "(env)", "model         diurnal", "(file)"} {
+		if !strings.Contains(shown.String(), expectedLine) {
+			t.Errorf("Shown config should contain '%s'\n%s", expectedLine, shown.String())
+		}
+	}
+}
+
+func TestDefaultDataDirFollowsXDG(t *testing.T) {
+	t.Setenv("XDG_DATA_HOME", "/data")
+	if dataDir := DefaultDataDir(); dataDir != "/data/weather-station" {
+		t.Errorf("Data directory %s should be in XDG data home", dataDir)
+	}
+	t.Setenv("XDG_DATA_HOME", "")
+	t.Setenv("HOME", "/home/gopher")
+	if dataDir := DefaultDataDir(); dataDir != "/home/gopher/.local/share/weather-station" {
+		t.Errorf("Data directory %s should be in ~/.local/share by default", dataDir)
+	}
+}
+
+func TestConfigFileFromEnv(t *testing.T) {
+	t.Setenv(FileEnv, writeConfigFile(`{"listen": "127.0.0.1:8080"}`, t))
+	config, err := loadWithArgs(nil, t)
+	if err != nil || config.Listen != "127.0.0.1:8080" {
+		t.Errorf("Listen address %s should come from config file, error '%s'", config.Listen, err)
+	}
+}
+
+func TestInvalidSettingsShouldFail(t *testing.T) {
+	if _, err := loadWithArgs([]string{"-config", writeConfigFile(`{"colour": "blue"}`, t)}, t); err == nil {
+		t.Errorf("Should have an error for unknown key")
+	}
+	if _, err := loadWithArgs([]string{"-config", filepath.Join(t.TempDir(), "missing.json")}, t); err == nil {
+		t.Errorf("Should have an error for missing config file")
+	}
+	if _, err := loadWithArgs([]string{"-seed", "abc"}, t); util.GetErrorKind(err) != util.InvalidArgumentError || util.GetErrorField(err) != SeedKey {
+		t.Errorf("Should have an invalid argument error for invalid seed instead of '%v'", err)
+	}
+	t.Setenv(EnvPrefix+"MODEL", "chaotic")
+	if _, err := loadWithArgs(nil, t); err == nil {
+		t.Errorf("Should have an error for invalid model")
+	}
+}
+
+func TestTOMLConfigFile(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "config.toml")
+	os.WriteFile(file, []byte(`# Weather station settings
+default_city = "PAR"  # Paris
+units = 'F'
+seed = 9_007_199_254_740_993
+listen = "127.0.0.1:8080"
+`), 0644)
+	config, err := loadWithArgs([]string{"-config", file}, t)
+	if err != nil {
+		t.Fatalf("Should not have an error '%s'", err)
+	}
+	if config.DefaultCity != "PAR" || config.Units != util.Fahrenheit || config.Seed != 9007199254740993 || config.Listen != "127.0.0.1:8080" {
+		t.Errorf("Unexpected config %+v", config)
+	}
+
+	for _, content := range []string{"[server]\nlisten = \":80\"", "default_city = PAR", "default_city = \"PAR", "seed = 1\nseed = 2", "units = \"F\" celsius"} {
+		os.WriteFile(file, []byte(content), 0644)
+		_, err = loadWithArgs([]string{"-config", file}, t)
+		if util.GetErrorKind(err) != util.InvalidArgumentError || util.GetErrorField(err) != FileFlag {
+			t.Errorf("Should have an invalid config error instead of '%v' for\n%s", err, content)
+		}
+	}
+}
+
+func TestYAMLConfigFile(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "config.yml")
+	os.WriteFile(file, []byte(`---
+# Weather station settings
+default_city: PAR  # Paris
+units: 'F'
+seed: 42
+listen: "127.0.0.1:8080"
+admin_secret: it's #1
+`), 0644)
+	config, err := loadWithArgs([]string{"-config", file}, t)
+	if err != nil {
+		t.Fatalf("Should not have an error '%s'", err)
+	}
+	if config.DefaultCity != "PAR" || config.Units != util.Fahrenheit || config.Seed != 42 || config.Listen != "127.0.0.1:8080" || config.AdminSecret != "it's" {
+		t.Errorf("Unexpected config %+v", config)
+	}
+
+	for _, content := range []string{"server:\n  listen: \":80\"", "cities:\n- PAR", "default_city: [PAR]", "default_city: \"PAR", "seed: 1\nseed: 2", "units: 'F' celsius", "listen :80"} {
+		os.WriteFile(file, []byte(content), 0644)
+		_, err = loadWithArgs([]string{"-config", file}, t)
+		if util.GetErrorKind(err) != util.InvalidArgumentError || util.GetErrorField(err) != FileFlag {
+			t.Errorf("Should have an invalid config error instead of '%v' for\n%s", err, content)
+		}
+	}
+}
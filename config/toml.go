@@ -0,0 +1,85 @@
+package config
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// tomlKeyValue matches key = value lines of TOML documents, bare keys only
+var tomlKeyValue = regexp.MustCompile(`^([A-Za-z0-9_-]+)\s*=\s*(.*)$`)
+
+var tomlNumber = regexp.MustCompile(`^[+-]?[0-9][0-9_]*(\.[0-9_]+)?([eE][+-]?[0-9]+)?$`)
+
+// decodeTOML returns the values of the key = value lines of a TOML document
+// as strings. Settings being flat, tables, arrays and multi-line strings are
+// not supported
+func decodeTOML(reader io.Reader) (map[string]interface{}, error) {
+	values := make(map[string]interface{})
+	scanner := bufio.NewScanner(reader)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		matches := tomlKeyValue.FindStringSubmatch(text)
+		if matches == nil {
+			return nil, fmt.Errorf("line %d should be like key = value", line)
+		}
+		if _, found := values[matches[1]]; found {
+			return nil, fmt.Errorf("line %d defines %s again", line, matches[1])
+		}
+		value, err := parseTOMLValue(matches[2])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %s", line, err)
+		}
+		values[matches[1]] = value
+	}
+	return values, scanner.Err()
+}
+
+// parseTOMLValue returns a basic or literal string, a number or a boolean
+// followed by an optional comment
+func parseTOMLValue(text string) (string, error) {
+	var value, rest string
+	switch {
+	case strings.HasPrefix(text, `"`):
+		end := 1
+		for ; end < len(text) && text[end] != '"'; end++ {
+			if text[end] == '\\' {
+				end++
+			}
+		}
+		if end >= len(text) {
+			return "", fmt.Errorf("string %s is not closed", text)
+		}
+		unquoted, err := strconv.Unquote(text[:end+1])
+		if err != nil {
+			return "", fmt.Errorf("string %s has an invalid escape", text[:end+1])
+		}
+		value, rest = unquoted, text[end+1:]
+	case strings.HasPrefix(text, "'"):
+		end := strings.Index(text[1:], "'")
+		if end < 0 {
+			return "", fmt.Errorf("string %s is not closed", text)
+		}
+		value, rest = text[1:end+1], text[end+2:]
+	default:
+		value = text
+		if comment := strings.Index(text, "#"); comment >= 0 {
+			value, rest = text[:comment], text[comment:]
+		}
+		value = strings.TrimSpace(value)
+		if value != "true" && value != "false" && !tomlNumber.MatchString(value) {
+			return "", fmt.Errorf("value '%s' should be a quoted string, a number or a boolean", value)
+		}
+		value = strings.ReplaceAll(value, "_", "")
+	}
+	if rest = strings.TrimSpace(rest); rest != "" && !strings.HasPrefix(rest, "#") {
+		return "", fmt.Errorf("unexpected '%s' after value", rest)
+	}
+	return value, nil
+}
@@ -0,0 +1,94 @@
+package config
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// yamlKeyValue matches key: value lines of YAML documents, plain keys only
+var yamlKeyValue = regexp.MustCompile(`^([A-Za-z0-9_-]+):(\s+(.*))?$`)
+
+// decodeYAML returns the values of the key: value lines of a YAML document as
+// strings. Settings being flat, nested mappings, sequences, flow collections
+// and multi-line scalars are not supported
+func decodeYAML(reader io.Reader) (map[string]interface{}, error) {
+	values := make(map[string]interface{})
+	scanner := bufio.NewScanner(reader)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimRight(scanner.Text(), " \t")
+		trimmed := strings.TrimSpace(text)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") || (line == 1 && trimmed == "---") {
+			continue
+		}
+		matches := yamlKeyValue.FindStringSubmatch(text)
+		if matches == nil {
+			return nil, fmt.Errorf("line %d should be like key: value", line)
+		}
+		if _, found := values[matches[1]]; found {
+			return nil, fmt.Errorf("line %d defines %s again", line, matches[1])
+		}
+		value, err := parseYAMLValue(matches[3])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %s", line, err)
+		}
+		values[matches[1]] = value
+	}
+	return values, scanner.Err()
+}
+
+// parseYAMLValue returns a double-quoted, single-quoted or plain scalar
+// followed by an optional comment
+func parseYAMLValue(text string) (string, error) {
+	var value, rest string
+	switch {
+	case text == "" || strings.HasPrefix(text, "#"):
+		return "", fmt.Errorf("value is missing, nested mappings are not supported")
+	case strings.HasPrefix(text, `"`):
+		end := 1
+		for ; end < len(text) && text[end] != '"'; end++ {
+			if text[end] == '\\' {
+				end++
+			}
+		}
+		if end >= len(text) {
+			return "", fmt.Errorf("string %s is not closed", text)
+		}
+		unquoted, err := strconv.Unquote(text[:end+1])
+		if err != nil {
+			return "", fmt.Errorf("string %s has an invalid escape", text[:end+1])
+		}
+		value, rest = unquoted, text[end+1:]
+	case strings.HasPrefix(text, "'"):
+		end := 1
+		for ; end < len(text); end++ {
+			if text[end] == '\'' {
+				// Quotes are escaped by doubling them
+				if end+1 < len(text) && text[end+1] == '\'' {
+					end++
+					continue
+				}
+				break
+			}
+		}
+		if end >= len(text) {
+			return "", fmt.Errorf("string %s is not closed", text)
+		}
+		value, rest = strings.ReplaceAll(text[1:end], "''", "'"), text[end+1:]
+	case text == "-" || strings.HasPrefix(text, "- ") || strings.ContainsAny(text[:1], "[{|>&*!"):
+		return "", fmt.Errorf("value '%s' should be a scalar", text)
+	default:
+		value = text
+		if comment := strings.Index(text, " #"); comment >= 0 {
+			value = text[:comment]
+		}
+		value = strings.TrimSpace(value)
+	}
+	if rest = strings.TrimSpace(rest); rest != "" && !strings.HasPrefix(rest, "#") {
+		return "", fmt.Errorf("unexpected '%s' after value", rest)
+	}
+	return value, nil
+}
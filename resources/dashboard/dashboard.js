@@ -0,0 +1,122 @@
+// Dashboard of the weather station, backed by /cities and
+// /cities/{city}/temps endpoints
+(function () {
+  "use strict";
+
+  var form = document.getElementById("query");
+  var citySelect = document.getElementById("city");
+  var compareSelect = document.getElementById("compare");
+  var dateInput = document.getElementById("date");
+  var durationSelect = document.getElementById("duration");
+  var unitsSelect = document.getElementById("units");
+  var errorMessage = document.getElementById("error");
+  var chart = document.getElementById("chart");
+  var stats = document.getElementById("stats");
+
+  function showError(message) {
+    errorMessage.textContent = message;
+    errorMessage.hidden = false;
+  }
+
+  // getJSON resolves with the JSON body of a successful response and rejects
+  // with the message of error responses
+  function getJSON(url) {
+    return fetch(url).then(function (response) {
+      return response.json().then(function (body) {
+        if (!response.ok) {
+          throw new Error(body.message || response.statusText);
+        }
+        return body;
+      });
+    });
+  }
+
+  function addOption(select, value, label) {
+    var option = document.createElement("option");
+    option.value = value;
+    option.textContent = label;
+    select.appendChild(option);
+  }
+
+  function addRow(body, cells) {
+    var row = document.createElement("tr");
+    cells.forEach(function (cell) {
+      var column = document.createElement("td");
+      column.textContent = cell;
+      row.appendChild(column);
+    });
+    body.appendChild(row);
+  }
+
+  // query returns the parameters of temps requests, times being read in the
+  // time zone of the city
+  function query() {
+    var params = new URLSearchParams();
+    params.set("duration", durationSelect.value);
+    params.set("units", unitsSelect.value);
+    if (dateInput.value) {
+      var date = dateInput.value;
+      params.set("date", date.length === 16 ? date + ":00" : date);
+    }
+    return params;
+  }
+
+  function cityCodes() {
+    var codes = [citySelect.value];
+    if (compareSelect.value && compareSelect.value !== citySelect.value) {
+      codes.push(compareSelect.value);
+    }
+    return codes;
+  }
+
+  function show() {
+    errorMessage.hidden = true;
+    var codes = cityCodes();
+    var params = query();
+
+    var chartParams = new URLSearchParams(params);
+    if (codes.length > 1) {
+      chartParams.set("cities", codes.slice(1).join(","));
+    }
+    chart.src = "cities/" + encodeURIComponent(codes[0]) + "/temps/chart.svg?" + chartParams;
+
+    Promise.all(codes.map(function (code) {
+      return getJSON("cities/" + encodeURIComponent(code) + "/temps?" + params);
+    })).then(function (allCityTemps) {
+      var statsRows = document.getElementById("stats-rows");
+      var tempsRows = document.getElementById("temps-rows");
+      statsRows.textContent = "";
+      tempsRows.textContent = "";
+      allCityTemps.forEach(function (cityTemps, index) {
+        var unit = cityTemps.Temps.length ? "°" + cityTemps.Temps[0].Unit : "";
+        addRow(statsRows, [codes[index], cityTemps.Min + unit, cityTemps.Max + unit, cityTemps.Average + unit, cityTemps.Temps.length]);
+        cityTemps.Temps.forEach(function (temp) {
+          addRow(tempsRows, [temp.City, temp.Time, temp.Temp + "°" + temp.Unit]);
+        });
+      });
+      stats.hidden = false;
+    }).catch(function (error) {
+      stats.hidden = true;
+      showError(error.message);
+    });
+  }
+
+  chart.addEventListener("error", function () {
+    showError("Chart could not be drawn");
+  });
+
+  form.addEventListener("submit", function (event) {
+    event.preventDefault();
+    show();
+  });
+
+  getJSON("cities").then(function (cities) {
+    cities.forEach(function (city) {
+      addOption(citySelect, city.iata_code, city.name);
+      addOption(compareSelect, city.iata_code, city.name);
+    });
+    show();
+  }).catch(function (error) {
+    showError("Cities could not be loaded: " + error.message);
+  });
+})();
@@ -0,0 +1,22 @@
+// Package resources holds read-only data embedded in the executable
+package resources
+
+import "embed"
+
+// CitiesJSON describes handled cities with their time zone and climate
+// samples. A cities file can be provided instead
+//
+//go:embed cities.json
+var CitiesJSON []byte
+
+// Dashboard holds the single page dashboard served by the server at /, in
+// dashboard directory
+//
+//go:embed dashboard
+var Dashboard embed.FS
+
+// Completion holds templates of shell completion scripts, in completion
+// directory
+//
+//go:embed completion
+var Completion embed.FS
@@ -0,0 +1,141 @@
+package server
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ekougs/weather-station/chart"
+	"github.com/ekougs/weather-station/util"
+
+	"github.com/gorilla/mux"
+)
+
+// defaultChartDuration is the period charted unless duration is requested
+const defaultChartDuration = "1M"
+
+// Size of charts in pixels unless width or height is requested
+const (
+	defaultChartWidth  = 800
+	defaultChartHeight = 400
+)
+
+// chartEncoder writes a chart of series in an image format
+type chartEncoder func(writer io.Writer, allSeries []chart.Series, width, height int, title string) error
+
+// chartHandler returns a handler responding with a chart of the temps the
+// temps request gives for its period, 1 month by default, encoded as
+// contentType. Cities of cities parameter are drawn on the same chart at the
+// same instants. Times are rendered in the city time zone unless tz is set
+func (server WeatherServer) chartHandler(contentType string, encode chartEncoder) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		cityCodes, err := server.getChartCityCodes(request)
+		if hasErrorWriteResponseAndNotify(writer, err) {
+			return
+		}
+
+		var timeUtils util.TimeUtils
+		timeUtils, err = server.getTimeUtils(request)
+		if hasErrorWriteResponseAndNotify(writer, err) {
+			return
+		}
+
+		var date time.Time
+		date, err = getDateParam(request, timeUtils, cityCodes[0])
+		if hasErrorWriteResponseAndNotify(writer, err) {
+			return
+		}
+
+		var rendering rendering
+		rendering, err = server.getRendering(request, date.Location())
+		if hasErrorWriteResponseAndNotify(writer, err) {
+			return
+		}
+
+		var width, height int
+		width, err = getSizeParam(request, "width", defaultChartWidth, chart.MinImageWidth, chart.MaxImageWidth)
+		if hasErrorWriteResponseAndNotify(writer, err) {
+			return
+		}
+		height, err = getSizeParam(request, "height", defaultChartHeight, chart.MinImageHeight, chart.MaxImageHeight)
+		if hasErrorWriteResponseAndNotify(writer, err) {
+			return
+		}
+
+		duration := request.FormValue("duration")
+		if duration == "" {
+			duration = defaultChartDuration
+		}
+		allSeries := make([]chart.Series, 0, len(cityCodes))
+		for _, cityCode := range cityCodes {
+			var cityTemps util.CityTemps
+			cityTemps, err = server.getCityTempsForChart(request, timeUtils, cityCode, date, duration)
+			if hasErrorWriteResponseAndNotify(writer, err) {
+				return
+			}
+			allSeries = append(allSeries, chart.FromCityTemps(cityCode, rendering.temps(cityTemps)))
+		}
+
+		title := "Temps of " + strings.Join(cityCodes, ", ") + " in °" + rendering.unit.Symbol()
+		var image bytes.Buffer
+		err = encode(&image, allSeries, width, height, title)
+		if hasErrorWriteResponseAndNotify(writer, err) {
+			return
+		}
+		writer.Header().Add(http.CanonicalHeaderKey("content-type"), contentType)
+		image.WriteTo(writer)
+	}
+}
+
+// getChartCityCodes returns the code of the city of the path followed by the
+// ones of the comma separated cities parameter
+func (server WeatherServer) getChartCityCodes(request *http.Request) ([]string, error) {
+	cityNames := []string{mux.Vars(request)["city"]}
+	if otherCities := request.FormValue("cities"); otherCities != "" {
+		cityNames = append(cityNames, strings.Split(otherCities, ",")...)
+	}
+	cityCodes := make([]string, 0, len(cityNames))
+	for _, cityName := range cityNames {
+		cityCode, err := server.dataUtils.GetCityCode(strings.TrimSpace(cityName))
+		if err != nil {
+			return nil, err
+		}
+		cityCodes = append(cityCodes, cityCode)
+	}
+	return cityCodes, nil
+}
+
+// getCityTempsForChart returns the temps of city for the period ending at
+// date in its time zone. Temps which could be provided are enough to draw
+func (server WeatherServer) getCityTempsForChart(request *http.Request, timeUtils util.TimeUtils, city string, date time.Time, duration string) (util.CityTemps, error) {
+	location, err := timeUtils.GetLocation(city)
+	if err != nil {
+		return util.CityTemps{}, err
+	}
+	datesChan, err := timeUtils.GetDatesForPeriod(request.Context(), date.In(location), duration)
+	if err != nil {
+		return util.CityTemps{}, err
+	}
+	cityTemps, err := server.tempProvider.GetForDates(request.Context(), city, datesChan)
+	if _, isDatesError := err.(util.DatesError); isDatesError && len(cityTemps.Temps) > 0 {
+		return cityTemps, nil
+	}
+	return cityTemps, err
+}
+
+// getSizeParam returns the size in pixels requested with name parameter,
+// defaultSize if none
+func getSizeParam(request *http.Request, name string, defaultSize, min, max int) (int, error) {
+	formattedSize := request.FormValue(name)
+	if formattedSize == "" {
+		return defaultSize, nil
+	}
+	size, err := strconv.Atoi(formattedSize)
+	if err != nil || size < min || size > max {
+		return 0, util.InvalidArgumentErrorf(name, "Expecting %s between %d and %d pixels", name, min, max)
+	}
+	return size, nil
+}
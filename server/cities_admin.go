@@ -0,0 +1,75 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/ekougs/weather-station/util"
+	"github.com/gorilla/mux"
+)
+
+// maxCityChangeSize bounds the body of city changes
+const maxCityChangeSize = 1 << 20
+
+// routeCitiesAdmin routes admin endpoints editing the cities file
+func routeCitiesAdmin(router *mux.Router, server WeatherServer) {
+	router.HandleFunc("/admin/cities", server.adminOnly(server.handleCityAddRequest)).Methods("POST")
+	router.HandleFunc("/admin/cities/{city}", server.adminOnly(server.handleCityShowRequest)).Methods("GET")
+	router.HandleFunc("/admin/cities/{city}", server.adminOnly(server.handleCityUpdateRequest)).Methods("PATCH")
+	router.HandleFunc("/admin/cities/{city}", server.adminOnly(server.handleCityRemoveRequest)).Methods("DELETE")
+}
+
+func (server WeatherServer) handleCityShowRequest(writer http.ResponseWriter, request *http.Request) {
+	definition, err := server.dataUtils.GetCityDefinition(mux.Vars(request)["city"])
+	if hasErrorWriteResponseAndNotify(writer, err) {
+		return
+	}
+	writeSucessfulResponse(writer, definition)
+}
+
+// handleCityAddRequest adds the city of a util.CityChange body
+func (server WeatherServer) handleCityAddRequest(writer http.ResponseWriter, request *http.Request) {
+	change, err := readCityChange(writer, request)
+	if hasErrorWriteResponseAndNotify(writer, err) {
+		return
+	}
+	definition, err := server.dataUtils.AddCity(change)
+	if hasErrorWriteResponseAndNotify(writer, err) {
+		return
+	}
+	writer.Header().Set(http.CanonicalHeaderKey("location"), "/admin/cities/"+definition.Code)
+	writeJSONResponse(writer, http.StatusCreated, definition)
+}
+
+// handleCityUpdateRequest changes the fields of the city set in a
+// util.CityChange body
+func (server WeatherServer) handleCityUpdateRequest(writer http.ResponseWriter, request *http.Request) {
+	change, err := readCityChange(writer, request)
+	if hasErrorWriteResponseAndNotify(writer, err) {
+		return
+	}
+	definition, err := server.dataUtils.UpdateCity(mux.Vars(request)["city"], change)
+	if hasErrorWriteResponseAndNotify(writer, err) {
+		return
+	}
+	writeSucessfulResponse(writer, definition)
+}
+
+// handleCityRemoveRequest removes the city and responds with its definition
+func (server WeatherServer) handleCityRemoveRequest(writer http.ResponseWriter, request *http.Request) {
+	definition, err := server.dataUtils.RemoveCity(mux.Vars(request)["city"])
+	if hasErrorWriteResponseAndNotify(writer, err) {
+		return
+	}
+	writeSucessfulResponse(writer, definition)
+}
+
+func readCityChange(writer http.ResponseWriter, request *http.Request) (util.CityChange, error) {
+	change := util.CityChange{}
+	decoder := json.NewDecoder(http.MaxBytesReader(writer, request.Body, maxCityChangeSize))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&change); err != nil {
+		return change, util.InvalidArgumentErrorf("body", "Expecting a city like {\"name\": ..., \"iata_code\": ..., \"iana_timezone\": ..., \"monthly_normals\": [[min, max], ...]}: %s", err)
+	}
+	return change, nil
+}
@@ -0,0 +1,71 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/ekougs/weather-station/util"
+)
+
+const lyonJSON = `{"name": "Lyon", "iata_code": "lys", "iana_timezone": "Europe/Paris",
+	"monthly_normals": [[3, 8], [3, 9], [5, 13], [7, 16], [11, 20], [14, 23], [16, 25], [16, 25], [13, 21], [10, 16], [6, 11], [4, 8]]}`
+
+func TestCitiesAdminNeedsAdminSecret(t *testing.T) {
+	server, handler := newTestServer(t)
+	for _, authorization := range []string{"", "Bearer wrong", "Basic " + testAdminSecret, testAdminSecret} {
+		for _, route := range [][]string{{"POST", "/admin/cities"}, {"GET", "/admin/cities/PAR"}, {"PATCH", "/admin/cities/PAR"}, {"DELETE", "/admin/cities/PAR"}} {
+			response := serveWithAuthorization(handler, route[0], route[1], lyonJSON, authorization)
+			assertErrorResponse(response, http.StatusUnauthorized, "unauthorized", "", t)
+			if response.Header().Get("WWW-Authenticate") != "Bearer" {
+				t.Errorf("Unauthorized %s %s should ask for a bearer token", route[0], route[1])
+			}
+		}
+	}
+	if _, err := server.dataUtils.GetCityDefinition("PAR"); err != nil {
+		t.Errorf("Unauthorized requests should not remove Paris: %s", err)
+	}
+
+	withoutSecret := server.WithAdminSecret("").newRouter()
+	assertErrorResponse(serve(withoutSecret, "GET", "/admin/cities/PAR", "", true), http.StatusNotFound, "not_found", "", t)
+}
+
+func TestCitiesAdminEditsCities(t *testing.T) {
+	server, handler := newTestServer(t)
+	response := serve(handler, "POST", "/admin/cities", lyonJSON, true)
+	if response.Code != http.StatusCreated || response.Header().Get("Location") != "/admin/cities/LYS" {
+		t.Fatalf("Expecting 201 with Location of Lyon instead of %d %s", response.Code, response.Body)
+	}
+	if _, err := server.dataUtils.GetCityCode("Lyon"); err != nil {
+		t.Errorf("Lyon should be handled once added: %s", err)
+	}
+
+	response = serve(handler, "PATCH", "/admin/cities/LYS", `{"name": "Lugdunum"}`, true)
+	updated := util.CityDefinition{}
+	json.Unmarshal(response.Body.Bytes(), &updated)
+	if response.Code != http.StatusOK || updated.Name != "Lugdunum" || updated.IanaTZ != "Europe/Paris" {
+		t.Errorf("Only the name of Lyon should change instead of %d %s", response.Code, response.Body)
+	}
+
+	response = serve(handler, "GET", "/admin/cities/lugdunum", "", true)
+	if response.Code != http.StatusOK {
+		t.Errorf("Updated city should be shown instead of %d %s", response.Code, response.Body)
+	}
+
+	if response = serve(handler, "DELETE", "/admin/cities/LYS", "", true); response.Code != http.StatusOK {
+		t.Errorf("Lyon should be removed instead of %d %s", response.Code, response.Body)
+	}
+	assertErrorResponse(serve(handler, "GET", "/admin/cities/LYS", "", true), http.StatusNotFound, "not_found", "city", t)
+}
+
+func TestCitiesAdminRejectsInvalidChanges(t *testing.T) {
+	_, handler := newTestServer(t)
+	assertErrorResponse(serve(handler, "POST", "/admin/cities", `{"name": "Lyon", "population": 500000}`, true), http.StatusBadRequest, "invalid_argument", "body", t)
+	assertErrorResponse(serve(handler, "POST", "/admin/cities", `{"name": `, true), http.StatusBadRequest, "invalid_argument", "body", t)
+	assertErrorResponse(serve(handler, "POST", "/admin/cities", strings.Replace(lyonJSON, "lys", "par", 1), true),
+		http.StatusBadRequest, "invalid_argument", "iata_code", t)
+	assertErrorResponse(serve(handler, "PATCH", "/admin/cities/PAR", `{"iana_timezone": "Europe/Lyon"}`, true), http.StatusBadRequest, "invalid_argument", "iana_timezone", t)
+	assertErrorResponse(serve(handler, "PATCH", "/admin/cities/Atlantis", `{"name": "Atlantide"}`, true), http.StatusNotFound, "not_found", "city", t)
+	assertErrorResponse(serve(handler, "DELETE", "/admin/cities/Atlantis", "", true), http.StatusNotFound, "not_found", "city", t)
+}
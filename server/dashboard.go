@@ -0,0 +1,43 @@
+package server
+
+import (
+	"fmt"
+	"io/fs"
+	"net/http"
+
+	"github.com/ekougs/weather-station/resources"
+
+	"github.com/gorilla/mux"
+)
+
+// dashboardFiles are the page of the dashboard and its assets
+var dashboardFiles = mustSub(resources.Dashboard, "dashboard", "index.html")
+
+// mustSub returns the dir of files, panicking when it has no page as files
+// are embedded at build time
+func mustSub(files fs.FS, dir, page string) fs.FS {
+	sub, err := fs.Sub(files, dir)
+	if err == nil {
+		_, err = fs.Stat(sub, page)
+	}
+	if err != nil {
+		panic(fmt.Sprintf("Embedded %s has no %s: %s", dir, page, err))
+	}
+	return sub
+}
+
+// routeDashboard serves the embedded dashboard at / and its scripts and
+// styles under /assets/ so that it works offline
+func routeDashboard(router *mux.Router) {
+	router.HandleFunc("/", handleDashboardRequest).Methods("GET")
+	router.PathPrefix("/assets/").Handler(http.StripPrefix("/assets/", http.FileServer(http.FS(dashboardFiles)))).Methods("GET")
+}
+
+func handleDashboardRequest(writer http.ResponseWriter, request *http.Request) {
+	page, err := fs.ReadFile(dashboardFiles, "index.html")
+	if hasErrorWriteResponseAndNotify(writer, err) {
+		return
+	}
+	writer.Header().Add(http.CanonicalHeaderKey("content-type"), "text/html; charset=utf-8")
+	writer.Write(page)
+}
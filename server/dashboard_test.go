@@ -0,0 +1,20 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/ekougs/weather-station/resources"
+)
+
+func TestMissingEmbeddedDashboardFailsEarly(t *testing.T) {
+	for _, dir := range []string{"dashbord", "../dashboard"} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("Embedded %s should not be accepted as dashboard", dir)
+				}
+			}()
+			mustSub(resources.Dashboard, dir, "index.html")
+		}()
+	}
+}
@@ -0,0 +1,158 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/ekougs/weather-station/util"
+
+	"github.com/gorilla/mux"
+)
+
+// minLiveInterval prevents clients from making the server generate readings
+// in a busy loop
+const minLiveInterval = 100 * time.Millisecond
+
+// liveHeartbeat is the period of comments keeping idle live connections open
+const liveHeartbeat = 15 * time.Second
+
+type liveReading struct {
+	temp util.CityTemp
+	err  error
+}
+
+// handleLiveRequest pushes a reading of the city station for every simulated
+// hour as Server-Sent Events, starting at date parameter or now
+// interval parameter like 2s accelerates the clock
+func (server WeatherServer) handleLiveRequest(writer http.ResponseWriter, request *http.Request) {
+	flusher, canFlush := writer.(http.Flusher)
+	if !canFlush {
+		writeErrorResponse(writer, fmt.Errorf("Streaming is not supported"))
+		return
+	}
+	vars := mux.Vars(request)
+	cityCode, err := server.dataUtils.GetCityCode(vars["city"])
+	if hasErrorWriteResponseAndNotify(writer, err) {
+		return
+	}
+
+	var timeUtils util.TimeUtils
+	timeUtils, err = server.getTimeUtils(request)
+	if hasErrorWriteResponseAndNotify(writer, err) {
+		return
+	}
+
+	var date time.Time
+	date, err = getDateParam(request, timeUtils, cityCode)
+	if hasErrorWriteResponseAndNotify(writer, err) {
+		return
+	}
+
+	var rendering rendering
+	rendering, err = server.getRendering(request, date.Location())
+	if hasErrorWriteResponseAndNotify(writer, err) {
+		return
+	}
+
+	var interval time.Duration
+	interval, err = server.getLiveInterval(request)
+	if hasErrorWriteResponseAndNotify(writer, err) {
+		return
+	}
+
+	header := writer.Header()
+	header.Set(http.CanonicalHeaderKey("content-type"), "text/event-stream")
+	header.Set(http.CanonicalHeaderKey("cache-control"), "no-cache")
+	ctx, cancel := server.liveContext(request.Context())
+	defer cancel()
+	readings := server.liveReadings(ctx, cityCode, date, interval)
+	heartbeat := time.NewTicker(liveHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case reading, open := <-readings:
+			if !open {
+				return
+			}
+			if reading.err != nil {
+				writeEvent(writer, "error", "", reading.err.Error())
+				flusher.Flush()
+				return
+			}
+			readingTime := time.Time(reading.temp.Time)
+			writeEvent(writer, "reading", fmt.Sprint(readingTime.Unix()), rendering.temp(reading.temp))
+		case <-heartbeat.C:
+			fmt.Fprint(writer, ": heartbeat\n\n")
+		case <-ctx.Done():
+			return
+		}
+		flusher.Flush()
+	}
+}
+
+// liveReadings provides a reading every interval for the next simulated hour
+// from start. Readings are stored like any other one
+func (server WeatherServer) liveReadings(ctx context.Context, city string, start time.Time, interval time.Duration) <-chan liveReading {
+	readings := make(chan liveReading)
+	go func() {
+		defer close(readings)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for simulatedTime := start; ; simulatedTime = simulatedTime.Add(time.Hour) {
+			temp, err := server.tempProvider.Get(city, simulatedTime)
+			select {
+			case readings <- liveReading{util.NewCityTemp(city, simulatedTime, temp), err}:
+			case <-ctx.Done():
+				return
+			}
+			if err != nil {
+				return
+			}
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return readings
+}
+
+// getLiveInterval returns the interval requested with interval parameter or
+// the server one if none
+func (server WeatherServer) getLiveInterval(request *http.Request) (time.Duration, error) {
+	interval := server.liveInterval
+	if formattedInterval := request.FormValue("interval"); "" != formattedInterval {
+		var err error
+		interval, err = time.ParseDuration(formattedInterval)
+		if err != nil {
+			return 0, util.InvalidArgumentErrorf("interval", "%s has not the right interval format. Expecting interval like 2s or 500ms", formattedInterval)
+		}
+	}
+	return interval, CheckLiveInterval(interval, "interval")
+}
+
+// CheckLiveInterval returns an invalid argument error about field if
+// interval is too short for live feeds
+func CheckLiveInterval(interval time.Duration, field string) error {
+	if interval < minLiveInterval {
+		return util.InvalidArgumentErrorf(field, "Interval %s should be at least %s", interval, minLiveInterval)
+	}
+	return nil
+}
+
+// writeEvent writes a Server-Sent Event whose data is data JSON
+func writeEvent(writer http.ResponseWriter, event, id string, data interface{}) {
+	dataJSON, err := json.Marshal(data)
+	if err != nil {
+		dataJSON, _ = json.Marshal(err.Error())
+	}
+	fmt.Fprintf(writer, "event: %s\n", event)
+	if id != "" {
+		fmt.Fprintf(writer, "id: %s\n", id)
+	}
+	fmt.Fprintf(writer, "data: %s\n\n", dataJSON)
+}
@@ -0,0 +1,66 @@
+package server
+
+import (
+	"bufio"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ekougs/weather-station/util"
+)
+
+func TestLiveIntervalsShorterThanMinimumAreRejected(t *testing.T) {
+	for _, interval := range []time.Duration{0, -time.Second, minLiveInterval - 1} {
+		err := CheckLiveInterval(interval, "live-interval")
+		if util.GetErrorKind(err) != util.InvalidArgumentError || util.GetErrorField(err) != "live-interval" {
+			t.Errorf("Interval %s should be an invalid live-interval instead of '%v'", interval, err)
+		}
+	}
+	if err := CheckLiveInterval(minLiveInterval, "live-interval"); err != nil {
+		t.Errorf("Minimum interval should be accepted: %s", err)
+	}
+}
+
+func TestLiveIntervalOfServerIsCheckedWithoutParameter(t *testing.T) {
+	server := WeatherServer{}.WithLiveInterval(0)
+	if _, err := server.getLiveInterval(httptest.NewRequest("GET", "/cities/PAR/live", nil)); util.GetErrorField(err) != "interval" {
+		t.Errorf("Zero server interval should be rejected instead of '%v'", err)
+	}
+	if interval, err := server.getLiveInterval(httptest.NewRequest("GET", "/cities/PAR/live?interval=2s", nil)); err != nil || interval != 2*time.Second {
+		t.Errorf("Requested interval should be 2s instead of %s, error '%v'", interval, err)
+	}
+	if _, err := server.getLiveInterval(httptest.NewRequest("GET", "/cities/PAR/live?interval=1ms", nil)); util.GetErrorField(err) != "interval" {
+		t.Errorf("Requested interval shorter than minimum should be rejected instead of '%v'", err)
+	}
+	if interval, _ := server.WithLiveInterval(time.Hour).getLiveInterval(httptest.NewRequest("GET", "/cities/PAR/live", nil)); interval != time.Hour {
+		t.Errorf("Server interval should be 1h instead of %s", interval)
+	}
+}
+
+func TestLiveFeedPushesReadingsOfNextHours(t *testing.T) {
+	_, handler := newTestServer(t)
+	httpServer := httptest.NewServer(handler)
+	defer httpServer.Close()
+	response, err := http.Get(httpServer.URL + "/cities/PAR/live?date=2015-04-02T17:00:00&interval=100ms")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+	if contentType := response.Header.Get("Content-Type"); contentType != "text/event-stream" {
+		t.Errorf("Expecting an event stream instead of %s", contentType)
+	}
+	var ids []string
+	scanner := bufio.NewScanner(response.Body)
+	for len(ids) < 2 && scanner.Scan() {
+		if id := strings.TrimPrefix(scanner.Text(), "id: "); id != scanner.Text() {
+			ids = append(ids, id)
+		}
+	}
+	start := time.Date(2015, 4, 2, 15, 0, 0, 0, time.UTC).Unix()
+	if len(ids) != 2 || ids[0] != fmt.Sprint(start) || ids[1] != fmt.Sprint(start+3600) {
+		t.Errorf("Expecting readings of 17:00 and 18:00 in Paris instead of %v", ids)
+	}
+}
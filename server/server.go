@@ -3,46 +3,153 @@ package server
 import (
 	"encoding/json"
 	"fmt"
+	"net"
 	"net/http"
+	"os"
+	"strings"
 	"time"
 
+	"github.com/ekougs/weather-station/chart"
 	"github.com/ekougs/weather-station/util"
 
 	"github.com/gorilla/mux"
 )
 
-var stopPwd = "Gophers$ince2014"
+const ndjsonContentType = "application/x-ndjson"
+
+// DefaultAddress is the TCP address the server listens on unless configured
+const DefaultAddress = ":1987"
 
 // WeatherServer provides HTTP access to all features available from the CLI
 type WeatherServer struct {
 	tempProvider util.TempProvider
 	timeUtils    util.TimeUtils
 	dataUtils    util.DataUtils
+	liveInterval time.Duration
+	unit         util.TempUnit
+	adminSecret  string
+	listenConfig
+	*lifecycle
+}
+
+// listenConfig tells where the server listens and whether it uses TLS
+// unixSocket takes precedence over address
+type listenConfig struct {
+	address, unixSocket     string
+	tlsCertFile, tlsKeyFile string
 }
 
 // NewWeatherServer provides a fully configured WeatherServer
 func NewWeatherServer(tempProvider util.TempProvider, timeUtils util.TimeUtils, dataUtils util.DataUtils) WeatherServer {
-	return WeatherServer{tempProvider, timeUtils, dataUtils}
+	return WeatherServer{tempProvider, timeUtils, dataUtils, time.Hour, util.Celsius, "", listenConfig{address: DefaultAddress}, nil}
+}
+
+// WithUnit returns a WeatherServer rendering temps in unit unless another one
+// is requested
+func (server WeatherServer) WithUnit(unit util.TempUnit) WeatherServer {
+	server.unit = unit
+	return server
+}
+
+// WithAdminSecret returns a WeatherServer offering admin endpoints to clients
+// sending secret as bearer token. They are disabled without secret
+func (server WeatherServer) WithAdminSecret(secret string) WeatherServer {
+	server.adminSecret = secret
+	return server
+}
+
+// WithAddress returns a WeatherServer listening on TCP address like
+// 127.0.0.1:8080 or :1987
+func (server WeatherServer) WithAddress(address string) WeatherServer {
+	server.address = address
+	return server
+}
+
+// WithUnixSocket returns a WeatherServer listening on the Unix domain socket
+// at path instead of a TCP address
+func (server WeatherServer) WithUnixSocket(path string) WeatherServer {
+	server.unixSocket = path
+	return server
+}
+
+// WithTLS returns a WeatherServer serving HTTPS with the PEM encoded
+// certificate and key files
+func (server WeatherServer) WithTLS(certFile, keyFile string) WeatherServer {
+	server.tlsCertFile, server.tlsKeyFile = certFile, keyFile
+	return server
+}
+
+// WithLiveInterval returns a WeatherServer whose live feeds provide a reading
+// for the next simulated hour every interval instead of every hour
+func (server WeatherServer) WithLiveInterval(interval time.Duration) WeatherServer {
+	server.liveInterval = interval
+	return server
 }
 
 // LaunchServer launch an HTTP server which offers all features offered by CLI
-func (server WeatherServer) LaunchServer() {
+// It returns when the server cannot start or stops serving. On SIGINT,
+// SIGTERM or admin shutdown request, it waits for requests in progress and
+// returns nil once they are done
+func (server WeatherServer) LaunchServer() error {
+	if (server.tlsCertFile == "") != (server.tlsKeyFile == "") {
+		return fmt.Errorf("TLS needs both a certificate file and a key file")
+	}
+	server.lifecycle = newLifecycle()
+	router := server.newRouter()
+
+	listener, err := server.listen()
+	if err != nil {
+		return err
+	}
+	defer listener.Close()
+	httpServer := &http.Server{Handler: router}
+	served := make(chan error, 1)
+	go func() {
+		if server.tlsCertFile != "" {
+			fmt.Printf("Launching HTTPS server on %s...\n", listener.Addr())
+			served <- httpServer.ServeTLS(listener, server.tlsCertFile, server.tlsKeyFile)
+		} else {
+			fmt.Printf("Launching HTTP server on %s...\n", listener.Addr())
+			served <- httpServer.Serve(listener)
+		}
+	}()
+	return server.waitForShutdown(httpServer, served)
+}
+
+// newRouter routes every endpoint of the server, admin ones only when an
+// admin secret is set
+func (server WeatherServer) newRouter() *mux.Router {
 	router := mux.NewRouter().StrictSlash(true)
-	fmt.Println("Launching HTTP server...")
+	router.NotFoundHandler = http.HandlerFunc(handleUnknownRoute)
+	router.MethodNotAllowedHandler = http.HandlerFunc(handleUnsupportedMethod)
 	router.HandleFunc("/cities", server.handleCityRequest).Methods("GET")
 	router.HandleFunc("/cities/{city}/temps", server.handleTempRequest).Methods("GET")
-	http.ListenAndServe(":1987", router)
+	router.HandleFunc("/cities/{city}/temps/chart.svg", server.chartHandler(chart.SVGContentType, chart.SVG)).Methods("GET")
+	router.HandleFunc("/cities/{city}/temps/chart.png", server.chartHandler(chart.PNGContentType, chart.PNG)).Methods("GET")
+	router.HandleFunc("/cities/{city}/live", server.handleLiveRequest).Methods("GET")
+	router.HandleFunc("/ws", server.handleWebSocket).Methods("GET")
+	routeDashboard(router)
+	if server.adminSecret != "" {
+		router.HandleFunc("/admin/shutdown", server.adminOnly(server.handleShutdownRequest)).Methods("POST")
+		routeCitiesAdmin(router, server)
+	}
+	return router
 }
 
-func handler(supportedMethod string, handler func(writer http.ResponseWriter, request *http.Request)) func(writer http.ResponseWriter, request *http.Request) {
-	return func(writer http.ResponseWriter, request *http.Request) {
-		method := request.Method
-		if method != supportedMethod {
-			http.Error(writer, method+" NOT ALLOWED", http.StatusMethodNotAllowed)
-			return
+func (server WeatherServer) listen() (net.Listener, error) {
+	network, address := "tcp", server.address
+	if server.unixSocket != "" {
+		network, address = "unix", server.unixSocket
+		// A socket file left by a previous run would prevent listening
+		if info, err := os.Stat(address); err == nil && info.Mode()&os.ModeSocket != 0 {
+			os.Remove(address)
 		}
-		handler(writer, request)
 	}
+	listener, err := net.Listen(network, address)
+	if err != nil {
+		return nil, fmt.Errorf("Cannot listen on %s %s: %w", network, address, err)
+	}
+	return listener, nil
 }
 
 func (server WeatherServer) handleTempRequest(writer http.ResponseWriter, request *http.Request) {
@@ -52,15 +159,31 @@ func (server WeatherServer) handleTempRequest(writer http.ResponseWriter, reques
 		return
 	}
 
+	var timeUtils util.TimeUtils
+	timeUtils, err = server.getTimeUtils(request)
+	if hasErrorWriteResponseAndNotify(writer, err) {
+		return
+	}
+
 	var date time.Time
-	date, err = server.getDateParam(request, cityCode)
+	date, err = getDateParam(request, timeUtils, cityCode)
+	if hasErrorWriteResponseAndNotify(writer, err) {
+		return
+	}
+
+	var rendering rendering
+	rendering, err = server.getRendering(request, date.Location())
 	if hasErrorWriteResponseAndNotify(writer, err) {
 		return
 	}
 
 	duration := request.FormValue("duration")
+	if duration != "" && acceptsNDJSON(request) {
+		server.streamTempsWithDuration(writer, request, timeUtils, cityCode, date, duration, rendering)
+		return
+	}
 	if duration != "" {
-		server.respondForTempWithDuration(writer, request, cityCode, date, duration)
+		server.respondForTempWithDuration(writer, request, timeUtils, cityCode, date, duration, rendering)
 		return
 	}
 
@@ -69,28 +192,122 @@ func (server WeatherServer) handleTempRequest(writer http.ResponseWriter, reques
 	if hasErrorWriteResponseAndNotify(writer, err) {
 		return
 	}
-	writeSucessfulResponse(writer, util.CityTemp{City: cityCode, Time: util.TempTime(date), Temp: temp})
+	writeSucessfulResponse(writer, rendering.temp(util.NewCityTemp(cityCode, date, temp)))
 }
 
-func (server WeatherServer) respondForTempWithDuration(writer http.ResponseWriter, request *http.Request, city string, date time.Time, duration string) {
-	datesChan, err := server.timeUtils.GetDatesForPeriod(date, duration)
+func (server WeatherServer) respondForTempWithDuration(writer http.ResponseWriter, request *http.Request, timeUtils util.TimeUtils, city string, date time.Time, duration string, rendering rendering) {
+	// Generation stops as soon as the client disconnects
+	ctx := request.Context()
+	datesChan, err := timeUtils.GetDatesForPeriod(ctx, date, duration)
+	if hasErrorWriteResponseAndNotify(writer, err) {
+		return
+	}
+	cityTemps, err := server.tempProvider.GetForDates(ctx, city, datesChan)
+	if datesError, isDatesError := err.(util.DatesError); isDatesError {
+		writePartialResponse(writer, rendering.temps(cityTemps), datesError)
+		return
+	}
 	if hasErrorWriteResponseAndNotify(writer, err) {
 		return
 	}
-	cityTemps := server.tempProvider.GetForDates(city, datesChan)
-	writeSucessfulResponse(writer, cityTemps)
+	writeSucessfulResponse(writer, rendering.temps(cityTemps))
 }
 
-func (server WeatherServer) getDateParam(request *http.Request, city string) (time.Time, error) {
-	parsedDate := request.FormValue("date")
-	var date time.Time
+// rendering tells how temps are rendered in responses
+type rendering struct {
+	location *time.Location
+	unit     util.TempUnit
+}
+
+func (rendering rendering) temp(temp util.CityTemp) util.CityTemp {
+	return temp.InUnit(rendering.unit).In(rendering.location)
+}
+
+func (rendering rendering) temps(temps util.CityTemps) util.CityTemps {
+	return temps.InUnit(rendering.unit).In(rendering.location)
+}
+
+// getRendering returns the location requested with tz parameter to render
+// times or cityLocation if none, and the unit requested with units parameter
+// or the server one if none
+func (server WeatherServer) getRendering(request *http.Request, cityLocation *time.Location) (rendering, error) {
+	rendering := rendering{cityLocation, server.unit}
 	var err error
+	if displayZone := request.FormValue("tz"); "" != displayZone {
+		rendering.location, err = util.LoadDisplayLocation(displayZone)
+		if err != nil {
+			return rendering, err
+		}
+	}
+	if unitName := request.FormValue("units"); "" != unitName {
+		rendering.unit, err = util.ParseTempUnit(unitName)
+	}
+	return rendering, err
+}
+
+// streamTempsWithDuration writes every temp of the period as a JSON line as
+// soon as it is generated then a last line with statistics and errors if any
+func (server WeatherServer) streamTempsWithDuration(writer http.ResponseWriter, request *http.Request, timeUtils util.TimeUtils, city string, date time.Time, duration string, rendering rendering) {
+	ctx := request.Context()
+	datesChan, err := timeUtils.GetDatesForPeriod(ctx, date, duration)
+	if hasErrorWriteResponseAndNotify(writer, err) {
+		return
+	}
+	writer.Header().Add(http.CanonicalHeaderKey("content-type"), ndjsonContentType)
+	encoder := json.NewEncoder(writer)
+	flusher, canFlush := writer.(http.Flusher)
+	cityTemps, err := server.tempProvider.StreamForDates(ctx, city, datesChan, func(temps []util.CityTemp) error {
+		for _, temp := range temps {
+			if err := encoder.Encode(rendering.temp(temp)); err != nil {
+				return err
+			}
+			if canFlush {
+				flusher.Flush()
+			}
+		}
+		return nil
+	})
+
+	summary := struct {
+		Min, Max, Average int
+		Error             string             `json:",omitempty"`
+		Failures          []util.DateFailure `json:",omitempty"`
+	}{}
+	stats := rendering.temps(cityTemps)
+	summary.Min, summary.Max, summary.Average = stats.Min, stats.Max, stats.Average
+	if err != nil {
+		summary.Error = err.Error()
+	}
+	if datesError, isDatesError := err.(util.DatesError); isDatesError {
+		summary.Failures = datesError.Failures
+	}
+	encoder.Encode(summary)
+}
+
+func acceptsNDJSON(request *http.Request) bool {
+	return strings.Contains(request.Header.Get("Accept"), ndjsonContentType)
+}
+
+// getTimeUtils returns the server TimeUtils with the DST policy requested
+// with dst parameter if any
+func (server WeatherServer) getTimeUtils(request *http.Request) (util.TimeUtils, error) {
+	dstPolicyName := request.FormValue("dst")
+	if "" == dstPolicyName {
+		return server.timeUtils, nil
+	}
+	dstPolicy, err := util.ParseDSTPolicy(dstPolicyName)
+	if err != nil {
+		return server.timeUtils, err
+	}
+	return server.timeUtils.WithDSTPolicy(dstPolicy), nil
+}
+
+func getDateParam(request *http.Request, timeUtils util.TimeUtils, city string) (time.Time, error) {
+	parsedDate := request.FormValue("date")
 	if "" == parsedDate {
-		date = server.timeUtils.GetTimeWithoutMinuteSecondNano(time.Now())
-	} else {
-		date, err = server.timeUtils.GetTime(parsedDate, city)
+		parsedDate = "now"
 	}
-	return date, err
+	return timeUtils.GetTime(parsedDate, city)
 }
 
 func (server WeatherServer) handleCityRequest(writer http.ResponseWriter, request *http.Request) {
@@ -110,10 +327,66 @@ func writeSucessfulResponse(writer http.ResponseWriter, result interface{}) {
 	fmt.Fprintf(writer, "%s", resultJSON)
 }
 
-func hasErrorWriteResponseAndNotify(responseWriter http.ResponseWriter, err error) bool {
+// errorResponse is the body of every error response
+// field is the request parameter the error is about if any
+type errorResponse struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	Field   string `json:"field,omitempty"`
+}
+
+var statusByErrorKind = map[util.ErrorKind]int{
+	util.InternalError:        http.StatusInternalServerError,
+	util.NotFoundError:        http.StatusNotFound,
+	util.InvalidArgumentError: http.StatusBadRequest,
+	util.DataError:            http.StatusInternalServerError,
+}
+
+func newErrorResponse(err error) errorResponse {
+	return errorResponse{util.GetErrorKind(err).String(), err.Error(), util.GetErrorField(err)}
+}
+
+// writePartialResponse responds with temps which could be provided and the
+// times which failed
+func writePartialResponse(writer http.ResponseWriter, partial util.CityTemps, datesError util.DatesError) {
+	response := struct {
+		errorResponse
+		Failures []util.DateFailure `json:"failures"`
+		Partial  util.CityTemps     `json:"partial"`
+	}{newErrorResponse(datesError), datesError.Failures, partial}
+	writeJSONResponse(writer, http.StatusInternalServerError, response)
+}
+
+func hasErrorWriteResponseAndNotify(writer http.ResponseWriter, err error) bool {
 	if err != nil {
-		http.Error(responseWriter, err.Error(), http.StatusInternalServerError)
+		writeErrorResponse(writer, err)
 		return true
 	}
 	return false
 }
+
+// writeErrorResponse responds with the status matching the kind of err and
+// an errorResponse body
+func writeErrorResponse(writer http.ResponseWriter, err error) {
+	writeJSONResponse(writer, statusByErrorKind[util.GetErrorKind(err)], newErrorResponse(err))
+}
+
+func writeJSONResponse(writer http.ResponseWriter, status int, response interface{}) {
+	responseJSON, err := json.Marshal(response)
+	if err != nil {
+		http.Error(writer, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writer.Header().Set(http.CanonicalHeaderKey("content-type"), "application/json")
+	writer.WriteHeader(status)
+	fmt.Fprintf(writer, "%s", responseJSON)
+}
+
+func handleUnknownRoute(writer http.ResponseWriter, request *http.Request) {
+	writeErrorResponse(writer, util.NotFoundErrorf("", "No resource at %s", request.URL.Path))
+}
+
+func handleUnsupportedMethod(writer http.ResponseWriter, request *http.Request) {
+	writeJSONResponse(writer, http.StatusMethodNotAllowed,
+		errorResponse{"method_not_allowed", request.Method + " NOT ALLOWED", ""})
+}
@@ -0,0 +1,152 @@
+package server
+
+import (
+	"encoding/json"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ekougs/weather-station/resources"
+	"github.com/ekougs/weather-station/util"
+)
+
+const testAdminSecret = "s3cret"
+
+// newTestServer returns a server storing cities and temps in a temporary
+// directory, with admin endpoints
+func newTestServer(t *testing.T) (WeatherServer, http.Handler) {
+	citiesFile := filepath.Join(t.TempDir(), "cities.json")
+	citiesJSON, err := os.ReadFile("../resources/cities.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.WriteFile(citiesFile, citiesJSON, 0644)
+	dataUtils, err := util.NewDataUtils(citiesFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	timeUtils, _ := util.NewTimeUtils(dataUtils)
+	tempProvider, _ := util.NewTempProvider(dataUtils)
+	server := NewWeatherServer(tempProvider, timeUtils, dataUtils).WithAdminSecret(testAdminSecret)
+	server.lifecycle = newLifecycle()
+	return server, server.newRouter()
+}
+
+// serve returns the response of handler to a request with body, as admin
+// when admin is set
+func serve(handler http.Handler, method, target, body string, admin bool) *httptest.ResponseRecorder {
+	authorization := ""
+	if admin {
+		authorization = "Bearer " + testAdminSecret
+	}
+	return serveWithAuthorization(handler, method, target, body, authorization)
+}
+
+func serveWithAuthorization(handler http.Handler, method, target, body, authorization string) *httptest.ResponseRecorder {
+	var bodyReader io.Reader
+	if body != "" {
+		bodyReader = strings.NewReader(body)
+	}
+	request := httptest.NewRequest(method, target, bodyReader)
+	if authorization != "" {
+		request.Header.Set("Authorization", authorization)
+	}
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, request)
+	return recorder
+}
+
+func assertErrorResponse(response *httptest.ResponseRecorder, expectedStatus int, expectedCode, expectedField string, t *testing.T) {
+	t.Helper()
+	body := errorResponse{}
+	json.Unmarshal(response.Body.Bytes(), &body)
+	if response.Code != expectedStatus || body.Code != expectedCode || body.Field != expectedField {
+		t.Errorf("Expecting %d %s about '%s' instead of %d %s", expectedStatus, expectedCode, expectedField, response.Code, response.Body)
+	}
+}
+
+func TestTempRequestErrors(t *testing.T) {
+	_, handler := newTestServer(t)
+	assertErrorResponse(serve(handler, "GET", "/cities/Atlantis/temps", "", false), http.StatusNotFound, "not_found", "city", t)
+	assertErrorResponse(serve(handler, "GET", "/cities/PAR/temps?date=someday", "", false), http.StatusBadRequest, "invalid_argument", "date", t)
+	assertErrorResponse(serve(handler, "GET", "/cities/PAR/temps?date=2015-04-02T17:00:00&units=kelvin", "", false), http.StatusBadRequest, "invalid_argument", "units", t)
+	assertErrorResponse(serve(handler, "GET", "/nowhere", "", false), http.StatusNotFound, "not_found", "", t)
+	assertErrorResponse(serve(handler, "DELETE", "/cities", "", false), http.StatusMethodNotAllowed, "method_not_allowed", "", t)
+}
+
+func TestTempRequest(t *testing.T) {
+	_, handler := newTestServer(t)
+	response := serve(handler, "GET", "/cities/Paris/temps?date=2015-04-02T17:00:00&units=F", "", false)
+	temp := struct{ City, Unit string }{}
+	if err := json.Unmarshal(response.Body.Bytes(), &temp); err != nil || response.Code != http.StatusOK || temp.City != "PAR" || temp.Unit != "F" {
+		t.Errorf("Expecting a temp of Paris in Fahrenheit instead of %d %s", response.Code, response.Body)
+	}
+}
+
+func TestChartRequests(t *testing.T) {
+	_, handler := newTestServer(t)
+	response := serve(handler, "GET", "/cities/PAR/temps/chart.svg?date=2015-04-02T17:00:00&duration=3D", "", false)
+	if response.Code != http.StatusOK || !strings.HasPrefix(response.Header().Get("Content-Type"), "image/svg") {
+		t.Errorf("Expecting an SVG chart instead of %d %s", response.Code, response.Header().Get("Content-Type"))
+	}
+	assertErrorResponse(serve(handler, "GET", "/cities/PAR/temps/chart.png?width=1", "", false), http.StatusBadRequest, "invalid_argument", "width", t)
+}
+
+func TestDashboardIsServed(t *testing.T) {
+	_, handler := newTestServer(t)
+	for _, test := range []struct{ target, file, contentType string }{
+		{"/", "index.html", "text/html"},
+		{"/assets/dashboard.js", "dashboard.js", "text/javascript"},
+		{"/assets/dashboard.css", "dashboard.css", "text/css"},
+	} {
+		embedded, err := fs.ReadFile(resources.Dashboard, "dashboard/"+test.file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		response := serve(handler, "GET", test.target, "", false)
+		if response.Code != http.StatusOK || response.Body.String() != string(embedded) {
+			t.Errorf("%s should serve embedded %s instead of %d", test.target, test.file, response.Code)
+		}
+		if contentType := response.Header().Get("Content-Type"); !strings.HasPrefix(contentType, test.contentType) {
+			t.Errorf("%s should be %s instead of %s", test.target, test.contentType, contentType)
+		}
+	}
+	assertErrorResponse(serve(handler, "GET", "/assets/missing.js", "", false), http.StatusNotFound, "", "", t)
+}
+
+func TestServerListensOnUnixSocket(t *testing.T) {
+	server, _ := newTestServer(t)
+	socket := filepath.Join(t.TempDir(), "weather.sock")
+	listener, err := server.WithUnixSocket(socket).listen()
+	if err != nil {
+		t.Fatal(err)
+	}
+	listener.Close()
+	if listener.Addr().Network() != "unix" {
+		t.Errorf("Expecting a unix listener instead of %s", listener.Addr().Network())
+	}
+	if _, err = server.WithAddress("256.0.0.1:1987").listen(); err == nil {
+		t.Error("Listening on an invalid address should fail")
+	}
+}
+
+func TestTempsAreStreamedAsJSONLines(t *testing.T) {
+	_, handler := newTestServer(t)
+	request := httptest.NewRequest("GET", "/cities/PAR/temps?date=2015-04-02T17:00:00&duration=7D", nil)
+	request.Header.Set("Accept", ndjsonContentType)
+	response := httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	lines := strings.Split(strings.TrimSpace(response.Body.String()), "\n")
+	if response.Header().Get("Content-Type") != ndjsonContentType || len(lines) != 9 || !response.Flushed {
+		t.Fatalf("Expecting 8 flushed temps and a summary instead of %s", response.Body)
+	}
+	summary := struct{ Min, Max, Average int }{}
+	if err := json.Unmarshal([]byte(lines[8]), &summary); err != nil || summary.Min > summary.Average || summary.Average > summary.Max {
+		t.Errorf("Last line should be statistics instead of %s", lines[8])
+	}
+}
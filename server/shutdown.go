@@ -0,0 +1,114 @@
+package server
+
+import (
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// shutdownTimeout bounds the wait for requests in progress on shutdown
+const shutdownTimeout = 30 * time.Second
+
+// lifecycle is shared by all copies of a launched WeatherServer
+// stopping is closed on shutdown so that live feeds, which never end by
+// themselves, let the server stop. liveConnections tracks WebSocket
+// connections which HTTP server shutdown does not wait for
+type lifecycle struct {
+	stopping          chan struct{}
+	shutdownRequested chan struct{}
+	liveConnections   sync.WaitGroup
+}
+
+func newLifecycle() *lifecycle {
+	return &lifecycle{stopping: make(chan struct{}), shutdownRequested: make(chan struct{}, 1)}
+}
+
+// waitForShutdown returns when the HTTP server stops serving by itself or
+// once requests in progress are done after a signal or an admin request
+func (server WeatherServer) waitForShutdown(httpServer *http.Server, served chan error) error {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(signals)
+
+	select {
+	case err := <-served:
+		return fmt.Errorf("Server stopped: %s", err)
+	case received := <-signals:
+		fmt.Printf("Received %s, shutting down...\n", received)
+	case <-server.shutdownRequested:
+		fmt.Println("Shutdown requested, shutting down...")
+	}
+
+	close(server.stopping)
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := httpServer.Shutdown(ctx); err != nil {
+		return fmt.Errorf("Requests still in progress after %s: %s", shutdownTimeout, err)
+	}
+	liveConnectionsClosed := make(chan struct{})
+	go func() {
+		server.liveConnections.Wait()
+		close(liveConnectionsClosed)
+	}()
+	select {
+	case <-liveConnectionsClosed:
+	case <-ctx.Done():
+		return fmt.Errorf("Live connections still open after %s", shutdownTimeout)
+	}
+	fmt.Println("Server stopped")
+	return nil
+}
+
+// liveContext returns a context of parent cancelled on server shutdown too
+func (server WeatherServer) liveContext(parent context.Context) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(parent)
+	go func() {
+		select {
+		case <-server.stopping:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return ctx, cancel
+}
+
+// handleShutdownRequest stops the server gracefully for admin clients
+func (server WeatherServer) handleShutdownRequest(writer http.ResponseWriter, request *http.Request) {
+	select {
+	case server.shutdownRequested <- struct{}{}:
+	default:
+		// Shutdown already requested
+	}
+	writeJSONResponse(writer, http.StatusAccepted, struct {
+		Status string `json:"status"`
+	}{"shutting down"})
+}
+
+// adminOnly returns a handler running handler only for clients sending the
+// admin secret like Authorization: Bearer <secret>
+func (server WeatherServer) adminOnly(handler http.HandlerFunc) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		if !server.isAdmin(request) {
+			writer.Header().Set(http.CanonicalHeaderKey("www-authenticate"), "Bearer")
+			writeJSONResponse(writer, http.StatusUnauthorized, errorResponse{"unauthorized", "Admin secret is missing or wrong", ""})
+			return
+		}
+		handler(writer, request)
+	}
+}
+
+func (server WeatherServer) isAdmin(request *http.Request) bool {
+	authorization := request.Header.Get("Authorization")
+	if !strings.HasPrefix(authorization, "Bearer ") {
+		return false
+	}
+	secret := strings.TrimPrefix(authorization, "Bearer ")
+	return subtle.ConstantTimeCompare([]byte(secret), []byte(server.adminSecret)) == 1
+}
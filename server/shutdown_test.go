@@ -0,0 +1,27 @@
+package server
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestShutdownIsRequestedByAdminOnly(t *testing.T) {
+	server, handler := newTestServer(t)
+	assertErrorResponse(serveWithAuthorization(handler, "POST", "/admin/shutdown", "", "Bearer wrong"), http.StatusUnauthorized, "unauthorized", "", t)
+	select {
+	case <-server.shutdownRequested:
+		t.Error("Shutdown should not be requested without admin secret")
+	default:
+	}
+
+	if response := serve(handler, "POST", "/admin/shutdown", "", true); response.Code != http.StatusAccepted {
+		t.Errorf("Expecting 202 instead of %d %s", response.Code, response.Body)
+	}
+	// A second request while shutting down does not block
+	serve(handler, "POST", "/admin/shutdown", "", true)
+	select {
+	case <-server.shutdownRequested:
+	default:
+		t.Error("Shutdown should be requested")
+	}
+}
@@ -0,0 +1,262 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/ekougs/weather-station/util"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// wsQueueSize is the number of messages waiting for a slow client before
+	// readings generation of its subscriptions is paused
+	wsQueueSize = 64
+	wsWriteWait = 10 * time.Second
+	wsPongWait  = 60 * time.Second
+	// wsPingPeriod must be less than wsPongWait
+	wsPingPeriod = wsPongWait * 9 / 10
+)
+
+const tempVariable = "temp"
+
+var supportedVariables = []string{tempVariable}
+
+var upgrader = websocket.Upgrader{ReadBufferSize: 1024, WriteBufferSize: 1024}
+
+// wsRequest is a message sent by clients
+// Action is subscribe or unsubscribe, no variables means all of them
+type wsRequest struct {
+	Action    string   `json:"action"`
+	Cities    []string `json:"cities"`
+	Variables []string `json:"variables"`
+}
+
+// wsMessage is a message sent to clients, Type is subscribed, unsubscribed,
+// reading or error
+type wsMessage struct {
+	Type      string         `json:"type"`
+	City      string         `json:"city,omitempty"`
+	Variables []string       `json:"variables,omitempty"`
+	Reading   *util.CityTemp `json:"reading,omitempty"`
+	Message   string         `json:"message,omitempty"`
+}
+
+type wsConnection struct {
+	server   WeatherServer
+	conn     *websocket.Conn
+	outgoing chan wsMessage
+	interval time.Duration
+	// subscriptions by city are changed by client requests and by readings
+	// which failed
+	lock          *sync.Mutex
+	subscriptions map[string]*wsSubscription
+}
+
+// wsSubscription is the subscription of a client to the readings of a city
+// Variables are the ones of the last subscribe request
+type wsSubscription struct {
+	cancel    context.CancelFunc
+	variables []string
+}
+
+// handleWebSocket lets a client subscribe and unsubscribe to cities live
+// readings with JSON messages like {"action": "subscribe", "cities": ["PAR"]}
+// interval parameter like 2s accelerates the clock like live feeds
+func (server WeatherServer) handleWebSocket(writer http.ResponseWriter, request *http.Request) {
+	// Counted before upgrading so that shutdown cannot miss the connection
+	server.liveConnections.Add(1)
+	defer server.liveConnections.Done()
+	interval, err := server.getLiveInterval(request)
+	if hasErrorWriteResponseAndNotify(writer, err) {
+		return
+	}
+	conn, err := upgrader.Upgrade(writer, request, nil)
+	if err != nil {
+		// Upgrade already responded to the client
+		return
+	}
+	ctx, cancel := server.liveContext(request.Context())
+	defer cancel()
+	connection := wsConnection{server, conn, make(chan wsMessage, wsQueueSize), interval, &sync.Mutex{}, make(map[string]*wsSubscription)}
+	go connection.writeMessages(ctx, cancel)
+	connection.readRequests(ctx)
+}
+
+// readRequests handles client requests until the connection fails
+func (connection wsConnection) readRequests(ctx context.Context) {
+	conn := connection.conn
+	conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	})
+	for {
+		_, requestJSON, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		var request wsRequest
+		if err = json.Unmarshal(requestJSON, &request); err != nil {
+			connection.send(ctx, wsMessage{Type: "error", Message: "Invalid request: " + err.Error()})
+			continue
+		}
+		connection.handleRequest(ctx, request)
+	}
+}
+
+func (connection wsConnection) handleRequest(ctx context.Context, request wsRequest) {
+	variables, err := checkVariables(request.Variables)
+	if err != nil {
+		connection.send(ctx, wsMessage{Type: "error", Message: err.Error()})
+		return
+	}
+	for _, city := range request.Cities {
+		cityCode, err := connection.server.dataUtils.GetCityCode(city)
+		if err != nil {
+			connection.send(ctx, wsMessage{Type: "error", City: city, Message: err.Error()})
+			continue
+		}
+		switch request.Action {
+		case "subscribe":
+			connection.subscribe(ctx, cityCode, variables)
+		case "unsubscribe":
+			connection.unsubscribe(ctx, cityCode)
+		default:
+			connection.send(ctx, wsMessage{Type: "error", Message: fmt.Sprintf("Unknown action '%s'. Expecting subscribe or unsubscribe", request.Action)})
+			return
+		}
+	}
+}
+
+// subscribe starts forwarding readings of city with variables, or only
+// changes variables of an existing subscription
+func (connection wsConnection) subscribe(ctx context.Context, city string, variables []string) {
+	connection.lock.Lock()
+	subscription, subscribed := connection.subscriptions[city]
+	if subscribed {
+		subscription.variables = variables
+	}
+	connection.lock.Unlock()
+	if !subscribed {
+		start, err := connection.server.timeUtils.GetTime("now", city)
+		if err != nil {
+			connection.send(ctx, wsMessage{Type: "error", City: city, Message: err.Error()})
+			return
+		}
+		subscriptionCtx, cancel := context.WithCancel(ctx)
+		subscription = &wsSubscription{cancel, variables}
+		connection.lock.Lock()
+		connection.subscriptions[city] = subscription
+		connection.lock.Unlock()
+		go connection.forwardReadings(subscriptionCtx, city, subscription, start)
+	}
+	connection.send(ctx, wsMessage{Type: "subscribed", City: city, Variables: variables})
+}
+
+func (connection wsConnection) unsubscribe(ctx context.Context, city string) {
+	connection.lock.Lock()
+	if subscription, subscribed := connection.subscriptions[city]; subscribed {
+		subscription.cancel()
+		delete(connection.subscriptions, city)
+	}
+	connection.lock.Unlock()
+	connection.send(ctx, wsMessage{Type: "unsubscribed", City: city})
+}
+
+// forwardReadings queues live readings of the city with the variables of
+// subscription for the client. Waiting for room in the queue pauses readings
+// generation. The subscription is removed once readings fail
+func (connection wsConnection) forwardReadings(ctx context.Context, city string, subscription *wsSubscription, start time.Time) {
+	defer connection.remove(city, subscription)
+	for reading := range connection.server.liveReadings(ctx, city, start, connection.interval) {
+		message := wsMessage{Type: "error", City: city}
+		if reading.err != nil {
+			message.Message = reading.err.Error()
+		} else {
+			connection.lock.Lock()
+			message = wsMessage{Type: "reading", City: city, Variables: subscription.variables}
+			connection.lock.Unlock()
+			if hasVariable(message.Variables, tempVariable) {
+				temp := reading.temp.InUnit(connection.server.unit)
+				message.Reading = &temp
+			}
+		}
+		if !connection.send(ctx, message) {
+			return
+		}
+	}
+}
+
+// remove removes subscription of city unless the client subscribed again
+// since
+func (connection wsConnection) remove(city string, subscription *wsSubscription) {
+	connection.lock.Lock()
+	defer connection.lock.Unlock()
+	subscription.cancel()
+	if connection.subscriptions[city] == subscription {
+		delete(connection.subscriptions, city)
+	}
+}
+
+func (connection wsConnection) send(ctx context.Context, message wsMessage) bool {
+	select {
+	case connection.outgoing <- message:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// writeMessages writes queued messages and heartbeats until the connection
+// fails, then cancels all subscriptions
+func (connection wsConnection) writeMessages(ctx context.Context, cancel context.CancelFunc) {
+	conn := connection.conn
+	ping := time.NewTicker(wsPingPeriod)
+	defer func() {
+		ping.Stop()
+		cancel()
+		conn.Close()
+	}()
+	for {
+		select {
+		case message := <-connection.outgoing:
+			conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+			if err := conn.WriteJSON(message); err != nil {
+				return
+			}
+		case <-ping.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(wsWriteWait)); err != nil {
+				return
+			}
+		case <-ctx.Done():
+			conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), time.Now().Add(wsWriteWait))
+			return
+		}
+	}
+}
+
+func hasVariable(variables []string, variable string) bool {
+	for _, candidate := range variables {
+		if candidate == variable {
+			return true
+		}
+	}
+	return false
+}
+
+func checkVariables(variables []string) ([]string, error) {
+	if len(variables) == 0 {
+		return supportedVariables, nil
+	}
+	for _, variable := range variables {
+		if variable != tempVariable {
+			return nil, fmt.Errorf("Unknown variable '%s'. Expecting one of %v", variable, supportedVariables)
+		}
+	}
+	return variables, nil
+}
@@ -0,0 +1,139 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// wsTestMessage is a message sent to clients, with the reading left
+// undecoded
+type wsTestMessage struct {
+	Type, City, Message string
+	Variables           []string
+	Reading             json.RawMessage
+}
+
+func dialWebSocket(t *testing.T) *websocket.Conn {
+	_, handler := newTestServer(t)
+	httpServer := httptest.NewServer(handler)
+	t.Cleanup(httpServer.Close)
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(httpServer.URL, "http")+"/ws?interval=100ms", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// readUntil returns the first message of messageType read within a second
+func readUntil(conn *websocket.Conn, messageType string, t *testing.T) wsTestMessage {
+	t.Helper()
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	for {
+		message := wsTestMessage{}
+		if err := conn.ReadJSON(&message); err != nil {
+			t.Fatalf("Expecting a %s message: %s", messageType, err)
+		}
+		if message.Type == messageType {
+			return message
+		}
+	}
+}
+
+func TestWebSocketClientsSubscribeAndUnsubscribe(t *testing.T) {
+	conn := dialWebSocket(t)
+	conn.WriteJSON(wsRequest{"subscribe", []string{"Paris"}, []string{"temp"}})
+	if message := readUntil(conn, "subscribed", t); message.City != "PAR" || len(message.Variables) != 1 || message.Variables[0] != "temp" {
+		t.Errorf("Expecting subscription of PAR to temp instead of %+v", message)
+	}
+	if message := readUntil(conn, "reading", t); message.City != "PAR" || len(message.Reading) == 0 || message.Variables[0] != "temp" {
+		t.Errorf("Expecting a temp of PAR instead of %+v", message)
+	}
+
+	conn.WriteJSON(wsRequest{"subscribe", []string{"Atlantis"}, nil})
+	if message := readUntil(conn, "error", t); message.City != "Atlantis" {
+		t.Errorf("Expecting an error about Atlantis instead of %+v", message)
+	}
+	conn.WriteJSON(wsRequest{"subscribe", []string{"PAR"}, []string{"humidity"}})
+	if message := readUntil(conn, "error", t); !strings.Contains(message.Message, "humidity") {
+		t.Errorf("Expecting an error about humidity instead of %+v", message)
+	}
+
+	conn.WriteJSON(wsRequest{"unsubscribe", []string{"PAR"}, nil})
+	readUntil(conn, "unsubscribed", t)
+	// A reading queued before unsubscribing may still be received
+	readings := 0
+	conn.SetReadDeadline(time.Now().Add(5 * minLiveInterval))
+	for message := (wsTestMessage{}); conn.ReadJSON(&message) == nil; message = (wsTestMessage{}) {
+		if message.Type == "reading" {
+			readings++
+		}
+	}
+	if readings > 1 {
+		t.Errorf("Readings should stop once unsubscribed, got %d", readings)
+	}
+}
+
+// newTestConnection returns a connection of a client which reads nothing,
+// with room for queueSize messages
+func newTestConnection(queueSize int, t *testing.T) (WeatherServer, wsConnection) {
+	server, _ := newTestServer(t)
+	return server, wsConnection{server, nil, make(chan wsMessage, queueSize), minLiveInterval, &sync.Mutex{}, make(map[string]*wsSubscription)}
+}
+
+func TestWebSocketSubscriptionIsRemovedWhenReadingsFail(t *testing.T) {
+	server, connection := newTestConnection(wsQueueSize, t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	connection.subscribe(ctx, "PAR", []string{"temp"})
+	if _, err := server.dataUtils.RemoveCity("PAR"); err != nil {
+		t.Fatal(err)
+	}
+	for message := range connection.outgoing {
+		if message.Type == "error" {
+			break
+		}
+	}
+	for wait := 0; wait < 100; wait++ {
+		connection.lock.Lock()
+		subscriptions := len(connection.subscriptions)
+		connection.lock.Unlock()
+		if subscriptions == 0 {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Error("Subscription of PAR should be removed once its readings failed")
+}
+
+func TestWebSocketReadingsWaitForSlowClients(t *testing.T) {
+	server, connection := newTestConnection(2, t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	connection.subscribe(ctx, "PAR", supportedVariables)
+	// The queue is full with the subscription and a reading, the next reading
+	// waits to be queued and the one after to be forwarded
+	time.Sleep(6 * minLiveInterval)
+	if stored, _ := server.dataUtils.GetStoredTemps("PAR"); len(stored) != 3 {
+		t.Errorf("Readings generation should be paused after 3 temps instead of %d", len(stored))
+	}
+
+	var times []time.Time
+	for len(times) < 4 {
+		if message := <-connection.outgoing; message.Type == "reading" {
+			times = append(times, time.Time(message.Reading.Time))
+		}
+	}
+	for i := 1; i < len(times); i++ {
+		if !times[i].Equal(times[i-1].Add(time.Hour)) {
+			t.Errorf("Readings of a slow client should not skip hours, got %s after %s", times[i], times[i-1])
+		}
+	}
+}
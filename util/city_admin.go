@@ -0,0 +1,315 @@
+package util
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// CitiesFileName is the name of the cities file written in the data directory
+// when cities embedded in the executable are edited
+const CitiesFileName = "cities.json"
+
+// SampleTemp is the range of temps of a city from its date until the next
+// sample. Dates are days of 2014 at 11:00 in the city time zone
+type SampleTemp struct {
+	Time      time.Time `json:"date"`
+	TempRange []int     `json:"temp_range"`
+}
+
+// CityDefinition describes a city like in the cities file
+type CityDefinition struct {
+	Name    string       `json:"name"`
+	Code    string       `json:"iata_code"`
+	IanaTZ  string       `json:"iana_timezone"`
+	Samples []SampleTemp `json:"sample_temps"`
+}
+
+// CityChange changes a city definition, empty fields are kept. Samples are
+// derived from MonthlyNormals, [min, max] temps of every month, when set
+type CityChange struct {
+	CityDefinition
+	MonthlyNormals [][]int `json:"monthly_normals,omitempty"`
+}
+
+// sampleDays are the days of every month having a sample
+var sampleDays = []int{1, 10, 20, 30}
+
+var iataCodePattern = regexp.MustCompile("^[A-Z]{3}$")
+
+// GetCityDefinition returns the definition of city, an IATA code or a name
+func (utils DataUtils) GetCityDefinition(city string) (CityDefinition, error) {
+	citiesData, err := utils.getCitiesData()
+	if err != nil {
+		return CityDefinition{}, err
+	}
+	index, err := citiesData.find(city)
+	if err != nil {
+		return CityDefinition{}, err
+	}
+	return citiesData[index].definition(), nil
+}
+
+// AddCity adds a city to the cities file and returns its definition
+func (utils DataUtils) AddCity(change CityChange) (CityDefinition, error) {
+	var added cityData
+	err := utils.editCities(func(cities citiesData) (citiesData, error) {
+		switch {
+		case strings.TrimSpace(change.Code) == "":
+			return nil, InvalidArgumentErrorf("iata_code", "A new city needs an IATA code")
+		case strings.TrimSpace(change.Name) == "":
+			return nil, InvalidArgumentErrorf("name", "A new city needs a name")
+		case change.IanaTZ == "":
+			return nil, InvalidArgumentErrorf("iana_timezone", "A new city needs a time zone")
+		case len(change.Samples) == 0 && len(change.MonthlyNormals) == 0:
+			return nil, InvalidArgumentErrorf("sample_temps", "A new city needs sample temps or monthly normals")
+		}
+		var err error
+		added, err = change.apply(cityData{})
+		if err != nil {
+			return nil, err
+		}
+		if err = cities.checkUnique(added, -1); err != nil {
+			return nil, err
+		}
+		return append(cities, added), nil
+	})
+	return added.definition(), err
+}
+
+// UpdateCity changes the definition of city, an IATA code or a name, in the
+// cities file and returns the new one. The IATA code cannot change
+func (utils DataUtils) UpdateCity(city string, change CityChange) (CityDefinition, error) {
+	var updated cityData
+	err := utils.editCities(func(cities citiesData) (citiesData, error) {
+		index, err := cities.find(city)
+		if err != nil {
+			return nil, err
+		}
+		updated, err = change.apply(cities[index])
+		if err != nil {
+			return nil, err
+		}
+		if err = cities.checkUnique(updated, index); err != nil {
+			return nil, err
+		}
+		cities[index] = updated
+		return cities, nil
+	})
+	return updated.definition(), err
+}
+
+// RemoveCity removes city, an IATA code or a name, from the cities file and
+// returns its definition. Its stored temps are kept
+func (utils DataUtils) RemoveCity(city string) (CityDefinition, error) {
+	var removed cityData
+	err := utils.editCities(func(cities citiesData) (citiesData, error) {
+		index, err := cities.find(city)
+		if err != nil {
+			return nil, err
+		}
+		removed = cities[index]
+		return append(cities[:index], cities[index+1:]...), nil
+	})
+	return removed.definition(), err
+}
+
+// editCities replaces cities by the edited ones in the cities file, or in the
+// data directory for cities embedded in the executable
+func (utils DataUtils) editCities(edit func(cities citiesData) (citiesData, error)) error {
+	utils.cache.lock.Lock()
+	defer utils.cache.lock.Unlock()
+
+	citiesFile := utils.citiesFile
+	if citiesFile == "" {
+		citiesFile = path.Join(utils.dataDir, CitiesFileName)
+	}
+	cities, err := utils.loadCitiesData()
+	if fileExists(citiesFile) {
+		// The file may have been edited since it was loaded
+		cities = citiesData{}
+		if err = readJSONFile(citiesFile, &cities); err != nil {
+			err = fmt.Errorf("Cannot read cities from %s: %w", citiesFile, err)
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	edited, err := edit(append(citiesData{}, cities...))
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(path.Dir(citiesFile), 0755); err != nil {
+		return err
+	}
+	if err = writeJSONFileAtomically(citiesFile, edited); err != nil {
+		return err
+	}
+	utils.cache.cities = edited
+	return nil
+}
+
+// find returns the index of city, an IATA code or a name
+func (cities citiesData) find(city string) (int, error) {
+	for index, cityData := range cities {
+		if strings.EqualFold(cityData.Code, city) || strings.EqualFold(cityData.Name, city) {
+			return index, nil
+		}
+	}
+	return -1, NotFoundErrorf("city", "Have not found city %s", city)
+}
+
+// checkUnique checks no city but the one at index except has the code or the
+// name of city
+func (cities citiesData) checkUnique(city cityData, except int) error {
+	for index, other := range cities {
+		switch {
+		case index == except:
+		case strings.EqualFold(other.Code, city.Code):
+			return InvalidArgumentErrorf("iata_code", "City %s already has IATA code %s", other.Name, city.Code)
+		case strings.EqualFold(other.Name, city.Name):
+			return InvalidArgumentErrorf("name", "City %s is already defined", other.Name)
+		}
+	}
+	return nil
+}
+
+// apply returns city changed and checks the result can generate temps
+func (change CityChange) apply(city cityData) (cityData, error) {
+	if code := strings.ToUpper(strings.TrimSpace(change.Code)); code != "" {
+		if city.Code != "" && code != city.Code {
+			return city, InvalidArgumentErrorf("iata_code", "IATA code of %s cannot change", city.Name)
+		}
+		if !iataCodePattern.MatchString(code) {
+			return city, InvalidArgumentErrorf("iata_code", "IATA code should be 3 letters like PAR instead of '%s'", change.Code)
+		}
+		city.Code = code
+	}
+	if name := strings.TrimSpace(change.Name); name != "" {
+		city.Name = name
+	}
+	if change.IanaTZ != "" {
+		city.IanaTZ = change.IanaTZ
+	}
+	location, err := time.LoadLocation(city.IanaTZ)
+	if err != nil {
+		return city, InvalidArgumentErrorf("iana_timezone", "Unknown time zone '%s'", city.IanaTZ)
+	}
+
+	changedSamples := samples{}
+	switch {
+	case len(change.Samples) > 0 && len(change.MonthlyNormals) > 0:
+		return city, InvalidArgumentErrorf("monthly_normals", "Expecting sample temps or monthly normals, not both")
+	case len(change.MonthlyNormals) > 0:
+		if changedSamples, err = samplesFromNormals(change.MonthlyNormals, location); err != nil {
+			return city, err
+		}
+	case len(change.Samples) > 0:
+		for index, sampleTemp := range change.Samples {
+			if sampleTemp.Time.IsZero() {
+				return city, InvalidArgumentErrorf("sample_temps", "Sample #%d needs a date", index+1)
+			}
+			changedSamples = append(changedSamples, sample(sampleTemp))
+		}
+	default:
+		changedSamples = append(changedSamples, city.Samples...)
+	}
+	// Samples keep their day when the time zone changes
+	for index, sampleTemp := range changedSamples {
+		_, month, day := sampleTemp.Time.Date()
+		changedSamples[index].Time = time.Date(2014, month, day, 11, 0, 0, 0, location)
+	}
+	city.Samples = changedSamples
+
+	if problems := city.validate(); len(problems) > 0 {
+		messages := make([]string, 0, len(problems))
+		for _, problem := range problems {
+			messages = append(messages, problem.Error())
+		}
+		return city, InvalidArgumentErrorf("sample_temps", "%s", strings.Join(messages, "; "))
+	}
+	return city, nil
+}
+
+// samplesFromNormals interpolates ranges of sample days between monthly
+// normals, taken as the ranges of the middle of their month
+func samplesFromNormals(normals [][]int, location *time.Location) (samples, error) {
+	if len(normals) != 12 {
+		return nil, InvalidArgumentErrorf("monthly_normals", "Expecting 12 monthly normals instead of %d", len(normals))
+	}
+	for index, normal := range normals {
+		if len(normal) != 2 || normal[0] >= normal[1] {
+			return nil, InvalidArgumentErrorf("monthly_normals", "Normal of %s should be like [min, max] with min below max instead of %v", time.Month(index+1), normal)
+		}
+	}
+
+	derived := samples{}
+	for month := time.January; month <= time.December; month++ {
+		monthDays := time.Date(2014, month+1, 0, 0, 0, 0, 0, time.UTC).Day()
+		for index, day := range sampleDays {
+			if day > monthDays {
+				continue
+			}
+			lastDay := monthDays
+			if index+1 < len(sampleDays) && sampleDays[index+1]-1 < lastDay {
+				lastDay = sampleDays[index+1] - 1
+			}
+			// Distance in months between the middle of the days of the sample
+			// and the middle of the month
+			offset := (float64(day+lastDay)/2 - float64(monthDays+1)/2) / float64(monthDays)
+			neighbour := (int(month) + 10) % 12
+			if offset > 0 {
+				neighbour = int(month) % 12
+			}
+			current := normals[month-1]
+			tempRange := make([]int, 2)
+			for bound := range tempRange {
+				tempRange[bound] = int(math.Round(float64(current[bound]) + math.Abs(offset)*float64(normals[neighbour][bound]-current[bound])))
+			}
+			derived = append(derived, sample{time.Date(2014, month, day, 11, 0, 0, 0, location), tempRange})
+		}
+	}
+	return derived, nil
+}
+
+func (cityData cityData) definition() CityDefinition {
+	definition := CityDefinition{cityData.Name, cityData.Code, cityData.IanaTZ, make([]SampleTemp, 0, len(cityData.Samples))}
+	for _, sample := range cityData.Samples {
+		definition.Samples = append(definition.Samples, SampleTemp(sample))
+	}
+	return definition
+}
+
+// writeJSONFileAtomically writes value as indented JSON in a temporary file
+// then renamed so that readers never see a partially written file
+func writeJSONFileAtomically(fileLocation string, value interface{}) error {
+	content, err := json.MarshalIndent(value, "", "  ")
+	if err != nil {
+		return err
+	}
+	tempFile, err := os.CreateTemp(path.Dir(fileLocation), "."+path.Base(fileLocation)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tempFile.Name())
+	_, err = tempFile.Write(append(content, '\n'))
+	if err == nil {
+		err = tempFile.Sync()
+	}
+	if closeErr := tempFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(tempFile.Name(), 0644)
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(tempFile.Name(), fileLocation)
+}
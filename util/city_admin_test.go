@@ -0,0 +1,110 @@
+package util
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+var parisNormals = [][]int{{3, 8}, {3, 9}, {5, 13}, {7, 16}, {11, 20}, {14, 23}, {16, 25}, {16, 25}, {13, 21}, {10, 16}, {6, 11}, {4, 8}}
+
+func newEditableDataUtils(t *testing.T) (DataUtils, string) {
+	citiesFile := filepath.Join(t.TempDir(), "cities.json")
+	citiesJSON, _ := os.ReadFile("../resources/cities.json")
+	os.WriteFile(citiesFile, citiesJSON, 0644)
+	dataUtils, err := NewDataUtils(citiesFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dataUtils, citiesFile
+}
+
+func TestAddedCityIsStoredWithSamplesFromNormals(t *testing.T) {
+	dataUtils, citiesFile := newEditableDataUtils(t)
+	added, err := dataUtils.AddCity(CityChange{CityDefinition{"Lyon", "lys", "Europe/Paris", nil}, parisNormals})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if added.Code != "LYS" || len(added.Samples) != 47 {
+		t.Errorf("Should have LYS code and 47 samples instead of %s and %d", added.Code, len(added.Samples))
+	}
+	// Mid-month samples get the normal of their month, first ones are between
+	// two months
+	for _, expected := range []SampleTemp{{time.Date(2014, 1, 10, 11, 0, 0, 0, time.UTC), []int{3, 8}},
+		{time.Date(2014, 5, 1, 11, 0, 0, 0, time.UTC), []int{10, 19}}, {time.Date(2014, 7, 30, 11, 0, 0, 0, time.UTC), []int{16, 25}}} {
+		for _, sample := range added.Samples {
+			if sample.Time.Month() == expected.Time.Month() && sample.Time.Day() == expected.Time.Day() &&
+				(sample.TempRange[0] != expected.TempRange[0] || sample.TempRange[1] != expected.TempRange[1]) {
+				t.Errorf("Sample of %s should be %v instead of %v", sample.Time, expected.TempRange, sample.TempRange)
+			}
+		}
+	}
+
+	reloaded, _ := NewDataUtils(citiesFile)
+	if _, err = reloaded.GetCityCode("Lyon"); err != nil {
+		t.Errorf("Added city should be in cities file: %s", err)
+	}
+	if problems := reloaded.Validate(); len(problems) > 0 {
+		t.Errorf("Cities should be valid: %v", problems)
+	}
+}
+
+func TestInvalidCitiesAreNotAdded(t *testing.T) {
+	dataUtils, _ := newEditableDataUtils(t)
+	_, err := dataUtils.AddCity(CityChange{CityDefinition{"Paris bis", "PAR", "Europe/Paris", nil}, parisNormals})
+	assertErrorKind(err, InvalidArgumentError, "iata_code", t)
+	_, err = dataUtils.AddCity(CityChange{CityDefinition{"Lyon", "LYS", "Europe/Lyon", nil}, parisNormals})
+	assertErrorKind(err, InvalidArgumentError, "iana_timezone", t)
+	_, err = dataUtils.AddCity(CityChange{CityDefinition{"Lyon", "LYS", "Europe/Paris", nil}, parisNormals[:11]})
+	assertErrorKind(err, InvalidArgumentError, "monthly_normals", t)
+	emptyNormals := append([][]int{{10, 10}}, parisNormals[1:]...)
+	_, err = dataUtils.AddCity(CityChange{CityDefinition{"Lyon", "LYS", "Europe/Paris", nil}, emptyNormals})
+	assertErrorKind(err, InvalidArgumentError, "monthly_normals", t)
+	samples := []SampleTemp{{time.Date(2014, 1, 1, 11, 0, 0, 0, time.UTC), []int{3, 8}}}
+	_, err = dataUtils.AddCity(CityChange{CityDefinition{"Lyon", "LYS", "Europe/Paris", samples}, nil})
+	assertErrorKind(err, InvalidArgumentError, "sample_temps", t)
+	if _, err = dataUtils.GetCityCode("Lyon"); err == nil {
+		t.Error("Invalid city should not be added")
+	}
+}
+
+func TestUpdatedCityKeepsSampleDaysInNewTimeZone(t *testing.T) {
+	dataUtils, _ := newEditableDataUtils(t)
+	updated, err := dataUtils.UpdateCity("par", CityChange{CityDefinition{"", "", "America/New_York", nil}, nil})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated.Name != "Paris" || updated.Samples[0].Time.Format(TimeFormat) != "2014-01-01T11:00:00-05:00" {
+		t.Errorf("Samples should be moved to New York time zone: %+v", updated.Samples[0])
+	}
+	_, err = dataUtils.UpdateCity("PAR", CityChange{CityDefinition{"", "CDG", "", nil}, nil})
+	assertErrorKind(err, InvalidArgumentError, "iata_code", t)
+	_, err = dataUtils.UpdateCity("Lyon", CityChange{})
+	assertErrorKind(err, NotFoundError, "city", t)
+}
+
+func TestRemovedCityIsNotHandledAnymore(t *testing.T) {
+	dataUtils, _ := newEditableDataUtils(t)
+	if _, err := dataUtils.RemoveCity("Dakar"); err != nil {
+		t.Fatal(err)
+	}
+	_, err := dataUtils.GetCityDefinition("DKR")
+	assertErrorKind(err, NotFoundError, "city", t)
+}
+
+func TestEditedEmbeddedCitiesAreStoredInDataDir(t *testing.T) {
+	citiesJSON, _ := os.ReadFile("../resources/cities.json")
+	dataDir := t.TempDir()
+	dataUtils, _ := NewDataUtilsFromJSON(citiesJSON, dataDir)
+	if _, err := dataUtils.RemoveCity("DKR"); err != nil {
+		t.Fatal(err)
+	}
+	reloaded, err := NewDataUtils(filepath.Join(dataDir, CitiesFileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cities, _ := reloaded.GetCities(); len(cities) != 2 {
+		t.Errorf("Should have 2 cities left instead of %v", cities)
+	}
+}
@@ -0,0 +1,30 @@
+package util
+
+import "sort"
+
+// GetStoredTemps returns the temps stored for a city sorted by time
+func (utils DataUtils) GetStoredTemps(city string) ([]Temp, error) {
+	cityFile, err := utils.getCityFileName(city)
+	if err != nil {
+		return nil, err
+	}
+
+	utils.cache.lock.Lock()
+	defer utils.cache.lock.Unlock()
+	stored, err := utils.loadTemps(cityFile)
+	if err != nil {
+		return nil, err
+	}
+	storedTemps := make([]Temp, len(stored.temps))
+	copy(storedTemps, stored.temps)
+	sort.Slice(storedTemps, func(i, j int) bool {
+		return storedTemps[i].Time.Before(storedTemps[j].Time)
+	})
+	return storedTemps, nil
+}
+
+// ImportTemps stores temps of a city like generated ones and returns the
+// number of temps added. Temps already stored for the same time are kept
+func (utils DataUtils) ImportTemps(city string, newTemps []Temp) (int, error) {
+	return utils.storeTemps(city, newTemps)
+}
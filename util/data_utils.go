@@ -6,6 +6,7 @@ import (
 	"os"
 	"path"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -35,36 +36,70 @@ type City struct {
 type Cities []City
 
 // DataUtils is the component used to manipulate local data
+// Copies share the same cache so they can be used concurrently
 type DataUtils struct {
-	citiesFile  string
+	citiesFile, dataDir string
+	cache               *dataCache
+}
+
+// dataCache keeps cities and stored temps loaded from files
+type dataCache struct {
+	lock        sync.Mutex
 	cities      citiesData
-	tempsByCity map[string]temps
+	tempsByCity map[string]*storedTemps
 }
 
 // Temp represents a temperature at a given time
 type Temp struct {
 	Time time.Time
 	Temp int
+	// Observed temps are real readings imported, taking precedence over
+	// generated ones
+	Observed bool `json:",omitempty"`
 }
 
 type temps []Temp
 
+// storedTemps are the temps of a city file indexed by Unix time
+type storedTemps struct {
+	temps  temps
+	byTime map[int64]int
+}
+
 var dataUtilsNil = DataUtils{}
 
 // NewDataUtils is the constructor for DataUtils
-// citiesFile provided must exist
+// citiesFile provided must exist, temps are stored in its directory
 func NewDataUtils(citiesFile string) (DataUtils, error) {
 	if _, err := os.Stat(citiesFile); os.IsNotExist(err) {
-		return dataUtilsNil, fmt.Errorf("No such file or directory: %s", citiesFile)
+		return dataUtilsNil, fmt.Errorf("Cannot read cities: %w", err)
+	}
+	return DataUtils{citiesFile, path.Dir(citiesFile), &dataCache{tempsByCity: make(map[string]*storedTemps)}}, nil
+}
+
+// NewDataUtilsFromJSON is the constructor for DataUtils reading cities from
+// citiesJSON, like the ones embedded in the executable, and storing temps in
+// dataDir
+func NewDataUtilsFromJSON(citiesJSON []byte, dataDir string) (DataUtils, error) {
+	cities := citiesData{}
+	if err := json.Unmarshal(citiesJSON, &cities); err != nil {
+		return dataUtilsNil, DataErrorf("Cannot read cities: %s", err)
 	}
-	return DataUtils{citiesFile: citiesFile, tempsByCity: make(map[string]temps)}, nil
+	return DataUtils{"", dataDir, &dataCache{cities: cities, tempsByCity: make(map[string]*storedTemps)}}, nil
+}
+
+// WithDataDir returns a DataUtils storing temps in dataDir, created when
+// the first temp is stored
+func (utils DataUtils) WithDataDir(dataDir string) DataUtils {
+	utils.dataDir = dataDir
+	return utils
 }
 
 // GetCities returns all cities handled by the application
 func (utils DataUtils) GetCities() (Cities, error) {
 	citiesData, err := utils.getCitiesData()
 	if err != nil {
-		return Cities{}, nil
+		return Cities{}, err
 	}
 	cities := Cities{}
 	for _, cityData := range citiesData {
@@ -78,20 +113,23 @@ func (city City) String() string {
 }
 
 func (utils DataUtils) getCitiesData() (citiesData, error) {
-	if utils.cities != nil {
-		return utils.cities, nil
-	}
+	utils.cache.lock.Lock()
+	defer utils.cache.lock.Unlock()
+	return utils.loadCitiesData()
+}
 
-	citiesJSONDecoder, error := getJSONDecoder(utils.citiesFile)
-	if error != nil {
-		return nil, error
+// loadCitiesData returns cities, from cache if already loaded
+// Cache lock must be held
+func (utils DataUtils) loadCitiesData() (citiesData, error) {
+	if utils.cache.cities != nil {
+		return utils.cache.cities, nil
 	}
+
 	cities := citiesData{}
-	error = citiesJSONDecoder.Decode(&cities)
-	if error != nil {
-		return nil, error
+	if err := readJSONFile(utils.citiesFile, &cities); err != nil {
+		return nil, fmt.Errorf("Cannot read cities from %s: %w", utils.citiesFile, err)
 	}
-	utils.cities = cities
+	utils.cache.cities = cities
 	return cities, nil
 }
 
@@ -100,54 +138,123 @@ func (utils DataUtils) getTemp(city string, requestTime time.Time) (int, error)
 	if err != nil {
 		return 0, err
 	}
-	if !fileExists(cityFile) {
-		err := fmt.Errorf("No such file or directory: %s", cityFile)
-		return 0, err
-	}
 
-	var decoder *json.Decoder
-	decoder, err = getJSONDecoder(cityFile)
+	utils.cache.lock.Lock()
+	defer utils.cache.lock.Unlock()
+	stored, err := utils.loadTemps(cityFile)
 	if err != nil {
 		return 0, err
 	}
-	temps := temps{}
-	decoder.Decode(&temps)
-	utils.tempsByCity[cityFile] = temps
-	for _, temp := range temps {
-		if temp.Time.Equal(requestTime) {
-			return temp.Temp, nil
-		}
+	if index, found := stored.byTime[requestTime.Unix()]; found {
+		return stored.temps[index].Temp, nil
 	}
 
 	return 0, fmt.Errorf("Value has not been generated yet for %s, %s", city, requestTime)
 }
 
-func (utils DataUtils) saveTemp(temp int, city string, requestTime time.Time) error {
+// getTempsForTimes returns temps of city stored for times by Unix time, with a
+// single lock of the cache
+func (utils DataUtils) getTempsForTimes(city string, times []time.Time) (map[int64]int, error) {
 	cityFile, err := utils.getCityFileName(city)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	var cityTemps temps
-	if !fileExists(cityFile) {
-		if _, err := os.Create(cityFile); err != nil {
-			return err
+
+	utils.cache.lock.Lock()
+	defer utils.cache.lock.Unlock()
+	stored, err := utils.loadTemps(cityFile)
+	if err != nil {
+		return nil, err
+	}
+	temps := make(map[int64]int, len(times))
+	for _, requestTime := range times {
+		if index, found := stored.byTime[requestTime.Unix()]; found {
+			temps[requestTime.Unix()] = stored.temps[index].Temp
 		}
-		cityTemps = temps{}
-	} else {
-		cityTemps = utils.tempsByCity[cityFile]
-	}
-	cityTemps = append(cityTemps, Temp{requestTime, temp})
-	utils.tempsByCity[cityFile] = cityTemps
-	var encoder *json.Encoder
-	encoder, err = getJSONEncoder(cityFile)
+	}
+	return temps, nil
+}
+
+func (utils DataUtils) saveTemp(temp int, city string, requestTime time.Time) error {
+	return utils.saveTemps(city, temps{Temp{requestTime, temp, false}})
+}
+
+// saveTemps stores new temps of a city with a single write of the city file
+// Temps already stored for the same time are kept
+func (utils DataUtils) saveTemps(city string, newTemps temps) error {
+	_, err := utils.storeTemps(city, newTemps)
+	return err
+}
+
+// storeTemps is saveTemps returning the number of temps actually added
+func (utils DataUtils) storeTemps(city string, newTemps temps) (int, error) {
+	cityFile, err := utils.getCityFileName(city)
 	if err != nil {
-		return err
+		return 0, err
 	}
-	err = encoder.Encode(cityTemps)
+
+	utils.cache.lock.Lock()
+	defer utils.cache.lock.Unlock()
+	stored, err := utils.loadTemps(cityFile)
 	if err != nil {
-		return err
+		return 0, err
 	}
-	return nil
+	added := 0
+	for _, temp := range newTemps {
+		if _, found := stored.byTime[temp.Time.Unix()]; found {
+			continue
+		}
+		stored.byTime[temp.Time.Unix()] = len(stored.temps)
+		stored.temps = append(stored.temps, temp)
+		added++
+	}
+	if added == 0 {
+		return 0, nil
+	}
+	if err = os.MkdirAll(path.Dir(cityFile), 0755); err != nil {
+		return 0, err
+	}
+	return added, writeJSONFile(cityFile, stored.temps)
+}
+
+// loadTemps returns temps stored in cityFile, from cache if already loaded
+// Cache lock must be held
+func (utils DataUtils) loadTemps(cityFile string) (*storedTemps, error) {
+	if stored, found := utils.cache.tempsByCity[cityFile]; found {
+		return stored, nil
+	}
+	stored := &storedTemps{temps{}, make(map[int64]int)}
+	if fileExists(cityFile) {
+		if err := readJSONFile(cityFile, &stored.temps); err != nil {
+			return nil, fmt.Errorf("Cannot read temps from %s: %w", cityFile, err)
+		}
+	}
+	for index, temp := range stored.temps {
+		stored.byTime[temp.Time.Unix()] = index
+	}
+	utils.cache.tempsByCity[cityFile] = stored
+	return stored, nil
+}
+
+// MigrateTemps copies temps files of every city from fromDir to the data
+// directory unless they are already there and returns the copied files
+func (utils DataUtils) MigrateTemps(fromDir string) ([]string, error) {
+	citiesData, err := utils.getCitiesData()
+	if err != nil {
+		return nil, err
+	}
+	var migrated []string
+	for _, cityData := range citiesData {
+		fromFile, toFile := path.Join(fromDir, cityData.Code+".json"), path.Join(utils.dataDir, cityData.Code+".json")
+		if !fileExists(fromFile) || fileExists(toFile) {
+			continue
+		}
+		if err = copyFile(fromFile, toFile); err != nil {
+			return migrated, fmt.Errorf("Cannot migrate temps of %s: %w", cityData.Name, err)
+		}
+		migrated = append(migrated, fromFile)
+	}
+	return migrated, nil
 }
 
 func (utils DataUtils) getCityFileName(city string) (string, error) {
@@ -173,7 +280,7 @@ func (utils DataUtils) GetCityCode(city string) (string, error) {
 			return cityData.Code, nil
 		}
 	}
-	return "", fmt.Errorf("Have not found city %s", city)
+	return "", NotFoundErrorf("city", "Have not found city %s", city)
 }
 
 func (utils DataUtils) getCityCode(city string) (string, error) {
@@ -186,31 +293,48 @@ func (utils DataUtils) getCityCode(city string) (string, error) {
 			return cityData.Code, nil
 		}
 	}
-	return "", fmt.Errorf("Have not found city %s", city)
+	return "", NotFoundErrorf("city", "Have not found city %s", city)
 }
 
 func (utils DataUtils) getResourceFileName(resourceName string) (string, error) {
-	resourcesDir := path.Dir(utils.citiesFile)
-	return resourcesDir + "/" + resourceName, nil
+	return utils.dataDir + "/" + resourceName, nil
 }
 
-func getJSONDecoder(fileLocation string) (*json.Decoder, error) {
-	jsonFileReader, error := os.Open(fileLocation)
-	if error != nil {
-		return nil, error
+func readJSONFile(fileLocation string, value interface{}) error {
+	jsonFileReader, err := os.Open(fileLocation)
+	if err != nil {
+		return err
 	}
-
-	jsonDecoder := json.NewDecoder(jsonFileReader)
-	return jsonDecoder, nil
+	defer jsonFileReader.Close()
+	if err = json.NewDecoder(jsonFileReader).Decode(value); err != nil {
+		// Content written by hand or by another version is not trusted
+		return Error{DataError, "", err}
+	}
+	return nil
 }
 
-func getJSONEncoder(fileLocation string) (*json.Encoder, error) {
+func writeJSONFile(fileLocation string, value interface{}) error {
 	jsonFileWriter, err := os.Create(fileLocation)
 	if err != nil {
-		return nil, err
+		return err
+	}
+	err = json.NewEncoder(jsonFileWriter).Encode(value)
+	if closeErr := jsonFileWriter.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// copyFile copies from into a new file to, in a directory created if needed
+func copyFile(from, to string) error {
+	content, err := os.ReadFile(from)
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(path.Dir(to), 0755); err != nil {
+		return err
 	}
-	jsonEncoder := json.NewEncoder(jsonFileWriter)
-	return jsonEncoder, nil
+	return os.WriteFile(to, content, 0644)
 }
 
 func fileExists(fileName string) bool {
@@ -1,8 +1,13 @@
 package util
 
 import (
+	"encoding/json"
+	"os"
+	"path/filepath"
 	"sort"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestCreationShouldFailIfCitiesFileProvidedDoesNotExist(t *testing.T) {
@@ -48,3 +53,108 @@ func contains(cities Cities, city City) bool {
 	})
 	return index >= 0 && index < numberOfCitiesExpected && city.Name == cities[index].Name
 }
+
+func TestTempsFromEmbeddedCitiesAreStoredInDataDir(t *testing.T) {
+	citiesJSON, _ := os.ReadFile("../resources/cities.json")
+	dataDir := filepath.Join(t.TempDir(), "data")
+	utils, err := NewDataUtilsFromJSON(citiesJSON, dataDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = utils.saveTemp(12, "PAR", time.Date(2015, 4, 2, 17, 0, 0, 0, time.UTC)); err != nil {
+		t.Fatalf("Should not have an error '%s'", err)
+	}
+	if !fileExists(filepath.Join(dataDir, "PAR.json")) {
+		t.Errorf("Temps should be stored in data directory %s", dataDir)
+	}
+	if _, err = NewDataUtilsFromJSON([]byte("{"), dataDir); err == nil {
+		t.Errorf("Should have an error for invalid cities JSON")
+	}
+}
+
+func TestMigrateTempsCopiesOnlyMissingFiles(t *testing.T) {
+	citiesJSON, _ := os.ReadFile("../resources/cities.json")
+	legacyDir, dataDir := t.TempDir(), t.TempDir()
+	os.WriteFile(filepath.Join(legacyDir, "PAR.json"), []byte("[]\n"), 0644)
+	os.WriteFile(filepath.Join(legacyDir, "NYC.json"), []byte("[]\n"), 0644)
+	os.WriteFile(filepath.Join(dataDir, "NYC.json"), []byte("kept"), 0644)
+	utils, _ := NewDataUtilsFromJSON(citiesJSON, dataDir)
+	migrated, err := utils.MigrateTemps(legacyDir)
+	if err != nil || len(migrated) != 1 || migrated[0] != filepath.Join(legacyDir, "PAR.json") {
+		t.Errorf("Only PAR temps should be migrated instead of %v, error '%s'", migrated, err)
+	}
+	if content, _ := os.ReadFile(filepath.Join(dataDir, "NYC.json")); string(content) != "kept" {
+		t.Errorf("Existing NYC temps should be kept instead of %s", content)
+	}
+}
+
+func TestValidateProvidedCities(t *testing.T) {
+	citiesJSON, _ := os.ReadFile("../resources/cities.json")
+	utils, _ := NewDataUtilsFromJSON(citiesJSON, t.TempDir())
+	if problems := utils.Validate(); len(problems) != 0 {
+		t.Errorf("Provided cities should be valid instead of %v", problems)
+	}
+}
+
+func TestValidateReportsEveryProblem(t *testing.T) {
+	citiesJSON, _ := os.ReadFile("../resources/cities.json")
+	cities := citiesData{}
+	json.Unmarshal(citiesJSON, &cities)
+	paris, newYork := cities[0], cities[1]
+	paris.Samples = append(samples{}, paris.Samples...)
+	paris.Samples[0].TempRange = []int{11, 6}
+	for index, sample := range newYork.Samples {
+		if sample.Time.Month() == time.May && sample.Time.Day() == 10 {
+			newYork.Samples = append(append(samples{}, newYork.Samples[:index]...), newYork.Samples[index+1:]...)
+			break
+		}
+	}
+	duplicate := cities[0]
+	duplicate.Name, duplicate.Code = "paris", "PRS"
+	fixture, _ := json.Marshal(citiesData{paris, newYork, {"Atlantis", "ATL", "Ocean/Atlantis", nil}, duplicate})
+	dataDir := t.TempDir()
+	os.WriteFile(filepath.Join(dataDir, "ATL.json"), []byte("not JSON"), 0644)
+	utils, _ := NewDataUtilsFromJSON(fixture, dataDir)
+
+	expectedProblems := []string{
+		"City Paris sample of 2014-01-01T11:00:00+01:00 should have a range like [min, max] with min below max instead of [11 6]",
+		"City New York has no sample for 2014-05-10T11:00:00-04:00",
+		"City Atlantis has an unknown time zone 'Ocean/Atlantis'",
+		"City Atlantis: ",
+		"City paris is defined more than once",
+	}
+	problems := utils.Validate()
+	for _, expected := range expectedProblems {
+		found := false
+		for _, problem := range problems {
+			found = found || strings.HasPrefix(problem.Error(), expected)
+		}
+		if !found {
+			t.Errorf("Should report '%s' among %v", expected, problems)
+		}
+	}
+	if len(problems) != len(expectedProblems) {
+		t.Errorf("Should only report %d problems instead of %v", len(expectedProblems), problems)
+	}
+}
+
+func TestExportedTempsCanBeImported(t *testing.T) {
+	citiesJSON, _ := os.ReadFile("../resources/cities.json")
+	exportUtils, _ := NewDataUtilsFromJSON(citiesJSON, t.TempDir())
+	paris := time.Date(2015, 4, 2, 17, 0, 0, 0, time.UTC)
+	exportUtils.saveTemps("PAR", temps{Temp{paris.Add(time.Hour), 13, false}, Temp{paris, 12, false}})
+	exported, err := exportUtils.GetStoredTemps("PAR")
+	if err != nil || len(exported) != 2 || !exported[0].Time.Equal(paris) {
+		t.Fatalf("Exported temps %v should be sorted by time, error '%s'", exported, err)
+	}
+
+	importUtils, _ := NewDataUtilsFromJSON(citiesJSON, t.TempDir())
+	importUtils.saveTemps("PAR", temps{Temp{paris, 20, false}})
+	imported, err := importUtils.ImportTemps("PAR", exported)
+	if err != nil || imported != 1 {
+		t.Errorf("Only one temp should be imported instead of %d, error '%s'", imported, err)
+	}
+	if temp, _ := importUtils.getTemp("PAR", paris); temp != 20 {
+		t.Errorf("Temp already stored %d should be kept", temp)
+	}
+}
@@ -0,0 +1,86 @@
+package util
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// DSTPolicy tells how to resolve a local time which does not exist (spring
+// forward gap) or exists twice (fall back overlap) in a city time zone
+type DSTPolicy int
+
+const (
+	// DSTEarliest picks the earliest instant matching the local time. In a gap
+	// it is the instant before the gap using the offset in effect after it
+	DSTEarliest DSTPolicy = iota
+	// DSTLatest picks the latest instant matching the local time. In a gap it
+	// is the instant after the gap using the offset in effect before it
+	DSTLatest
+	// DSTReject fails for local times in a gap or an overlap
+	DSTReject
+)
+
+var dstPolicyNames = []string{"earliest", "latest", "reject"}
+
+// DSTPolicies lists the names of available DST policies
+var DSTPolicies = strings.Join(dstPolicyNames, ", ")
+
+// ParseDSTPolicy returns the DST policy named earliest, latest or reject
+func ParseDSTPolicy(name string) (DSTPolicy, error) {
+	for policy, policyName := range dstPolicyNames {
+		if strings.EqualFold(policyName, name) {
+			return DSTPolicy(policy), nil
+		}
+	}
+	return DSTEarliest, InvalidArgumentErrorf("dst", "Unknown DST policy '%s'. Expecting one of %s", name, DSTPolicies)
+}
+
+func (policy DSTPolicy) String() string {
+	return dstPolicyNames[policy]
+}
+
+// resolve returns the instant for wallClock, a time whose fields are read as
+// a local time in location, according to the policy
+func (policy DSTPolicy) resolve(wallClock time.Time, location *time.Location) (time.Time, error) {
+	wallClock = getWallClock(wallClock)
+	// Offsets in effect a day before and after surround any transition
+	_, offsetBefore := wallClock.AddDate(0, 0, -1).In(location).Zone()
+	_, offsetAfter := wallClock.AddDate(0, 0, 1).In(location).Zone()
+	instantWithOffsetBefore := wallClock.Add(-time.Duration(offsetBefore) * time.Second).In(location)
+	instantWithOffsetAfter := wallClock.Add(-time.Duration(offsetAfter) * time.Second).In(location)
+
+	earliest, latest := instantWithOffsetBefore, instantWithOffsetAfter
+	if latest.Before(earliest) {
+		earliest, latest = latest, earliest
+	}
+	earliestExists, latestExists := hasWallClock(earliest, wallClock), hasWallClock(latest, wallClock)
+	switch {
+	case earliest.Equal(latest), earliestExists && !latestExists:
+		return earliest, nil
+	case latestExists && !earliestExists:
+		return latest, nil
+	}
+
+	isGap := !earliestExists
+	if policy == DSTReject {
+		if isGap {
+			return timeNil, fmt.Errorf("%s does not exist in %s because of daylight saving time change", wallClock.Format(LocalTimeFormat), location)
+		}
+		return timeNil, fmt.Errorf("%s is ambiguous in %s because of daylight saving time change, it can be %s or %s",
+			wallClock.Format(LocalTimeFormat), location, earliest.Format(TimeFormat), latest.Format(TimeFormat))
+	}
+	if policy == DSTLatest {
+		return latest, nil
+	}
+	return earliest, nil
+}
+
+// getWallClock returns local date and time fields of input as a time in UTC
+func getWallClock(input time.Time) time.Time {
+	return time.Date(input.Year(), input.Month(), input.Day(), input.Hour(), input.Minute(), input.Second(), input.Nanosecond(), time.UTC)
+}
+
+func hasWallClock(input, wallClock time.Time) bool {
+	return getWallClock(input).Equal(wallClock)
+}
@@ -0,0 +1,106 @@
+package util
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func assertTimeWithDSTPolicy(policy DSTPolicy, formattedTime, city, expectedTime string, t *testing.T) {
+	actualTime, err := utils.WithDSTPolicy(policy).GetTime(formattedTime, city)
+	if err != nil {
+		t.Errorf("Should not have an error for '%s' with %s policy '%s'", formattedTime, policy, err)
+		return
+	}
+	actualFormattedTime := actualTime.Format(TimeFormat)
+	if expectedTime != actualFormattedTime {
+		t.Errorf("Expected time '%s' for '%s' with %s policy is different from the actual one '%s'", expectedTime,
+			formattedTime, policy, actualFormattedTime)
+	}
+}
+
+func TestGetTimeInSpringForwardGap(t *testing.T) {
+	assertTimeWithDSTPolicy(DSTEarliest, "2015-03-29T02:30:00", "PAR", "2015-03-29T01:00:00+01:00", t)
+	assertTimeWithDSTPolicy(DSTLatest, "2015-03-29T02:30:00", "PAR", "2015-03-29T03:00:00+02:00", t)
+	assertTimeWithDSTPolicy(DSTEarliest, "2015-03-08T02:00:00", "NYC", "2015-03-08T01:00:00-05:00", t)
+	assertTimeWithDSTPolicy(DSTLatest, "2015-03-08 02:00", "NYC", "2015-03-08T03:00:00-04:00", t)
+}
+
+func TestGetTimeInFallBackOverlap(t *testing.T) {
+	assertTimeWithDSTPolicy(DSTEarliest, "2015-10-25T02:00:00", "PAR", "2015-10-25T02:00:00+02:00", t)
+	assertTimeWithDSTPolicy(DSTLatest, "2015-10-25T02:00:00", "PAR", "2015-10-25T02:00:00+01:00", t)
+	assertTimeWithDSTPolicy(DSTEarliest, "2015-11-01T01:00:00", "NYC", "2015-11-01T01:00:00-04:00", t)
+	assertTimeWithDSTPolicy(DSTLatest, "2015-11-01 01h", "NYC", "2015-11-01T01:00:00-05:00", t)
+}
+
+func TestGetTimeOutsideTransitionsDoesNotDependOnPolicy(t *testing.T) {
+	for _, policy := range []DSTPolicy{DSTEarliest, DSTLatest, DSTReject} {
+		assertTimeWithDSTPolicy(policy, "2015-10-25T03:00:00", "PAR", "2015-10-25T03:00:00+01:00", t)
+		assertTimeWithDSTPolicy(policy, "2015-03-29T03:00:00", "PAR", "2015-03-29T03:00:00+02:00", t)
+		assertTimeWithDSTPolicy(policy, "2015-03-29T02:30:00", "DKR", "2015-03-29T02:00:00Z", t)
+	}
+}
+
+func TestGetTimeWithRejectPolicyShouldFail(t *testing.T) {
+	rejectUtils := utils.WithDSTPolicy(DSTReject)
+	for _, formattedTime := range []string{"2015-03-29T02:30:00", "2015-10-25T02:00:00", "2015-10-25 02h"} {
+		if _, err := rejectUtils.GetTime(formattedTime, "PAR"); err == nil {
+			t.Errorf("Should have an error for '%s' with reject policy", formattedTime)
+		}
+	}
+}
+
+func TestGetDatesForPeriodAcrossSpringForward(t *testing.T) {
+	location, _ := time.LoadLocation("Europe/Paris")
+	endTime := time.Date(2015, 3, 30, 2, 0, 0, 0, location)
+	expectedDates := map[DSTPolicy][]time.Time{
+		DSTEarliest: {
+			time.Date(2015, 3, 28, 2, 0, 0, 0, location),
+			time.Date(2015, 3, 29, 0, 0, 0, 0, time.UTC),
+			time.Date(2015, 3, 30, 2, 0, 0, 0, location),
+		},
+		DSTLatest: {
+			time.Date(2015, 3, 28, 2, 0, 0, 0, location),
+			time.Date(2015, 3, 29, 1, 0, 0, 0, time.UTC),
+			time.Date(2015, 3, 30, 2, 0, 0, 0, location),
+		},
+		DSTReject: {
+			time.Date(2015, 3, 28, 2, 0, 0, 0, location),
+			time.Date(2015, 3, 30, 2, 0, 0, 0, location),
+		},
+	}
+	for policy, expected := range expectedDates {
+		datesChan, err := utils.WithDSTPolicy(policy).GetDatesForPeriod(context.Background(), endTime, "2D")
+		if err != nil {
+			t.Errorf("Should not have an error with %s policy '%s'", policy, err)
+			continue
+		}
+		actualDates := make([]time.Time, 0, 3)
+		for dates := range datesChan {
+			actualDates = append(actualDates, dates...)
+		}
+		assertDatesEqual(actualDates, expected, t)
+	}
+}
+
+func TestParseDSTPolicy(t *testing.T) {
+	policy, err := ParseDSTPolicy("Latest")
+	if err != nil || policy != DSTLatest {
+		t.Errorf("Policy %s should be latest, error '%s'", policy, err)
+	}
+	if _, err = ParseDSTPolicy("nimportequoi"); err == nil {
+		t.Errorf("Should have an error for unknown policy")
+	}
+}
+
+func TestCityTempReportsResolvedOffset(t *testing.T) {
+	location, _ := time.LoadLocation("Europe/Paris")
+	cityTemp := NewCityTemp("PAR", time.Date(2015, 10, 25, 0, 0, 0, 0, time.UTC).In(location), 12)
+	if cityTemp.UTCOffset != "+02:00" {
+		t.Errorf("UTC offset %s should be +02:00", cityTemp.UTCOffset)
+	}
+	cityTemp = NewCityTemp("PAR", time.Date(2015, 10, 25, 1, 0, 0, 0, time.UTC).In(location), 12)
+	if cityTemp.UTCOffset != "+01:00" {
+		t.Errorf("UTC offset %s should be +01:00", cityTemp.UTCOffset)
+	}
+}
@@ -0,0 +1,90 @@
+package util
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrorKind classifies errors so that callers like the HTTP server can react
+// to them without parsing messages
+type ErrorKind int
+
+const (
+	// InternalError is the kind of every unclassified error
+	InternalError ErrorKind = iota
+	// NotFoundError is the kind of errors about a missing resource like a city
+	NotFoundError
+	// InvalidArgumentError is the kind of errors about a malformed input like
+	// a date or a duration
+	InvalidArgumentError
+	// DataError is the kind of errors about stored data which cannot be read
+	// like a malformed cities or temps file
+	DataError
+)
+
+var errorKindNames = []string{"internal", "not_found", "invalid_argument", "data"}
+
+func (kind ErrorKind) String() string {
+	return errorKindNames[kind]
+}
+
+// Error is an error of a known kind about the input named Field like city,
+// date or duration
+type Error struct {
+	Kind  ErrorKind
+	Field string
+	Err   error
+}
+
+func (err Error) Error() string {
+	return err.Err.Error()
+}
+
+// Unwrap returns the underlying error
+func (err Error) Unwrap() error {
+	return err.Err
+}
+
+// NotFoundErrorf returns a NotFoundError about field
+func NotFoundErrorf(field, format string, args ...interface{}) error {
+	return Error{NotFoundError, field, fmt.Errorf(format, args...)}
+}
+
+// InvalidArgumentErrorf returns an InvalidArgumentError about field
+func InvalidArgumentErrorf(field, format string, args ...interface{}) error {
+	return Error{InvalidArgumentError, field, fmt.Errorf(format, args...)}
+}
+
+// DataErrorf returns a DataError
+func DataErrorf(format string, args ...interface{}) error {
+	return Error{DataError, "", fmt.Errorf(format, args...)}
+}
+
+// GetErrorKind returns the kind of err or of an error it wraps, InternalError
+// if it is not classified
+func GetErrorKind(err error) ErrorKind {
+	var typedErr Error
+	if errors.As(err, &typedErr) {
+		return typedErr.Kind
+	}
+	return InternalError
+}
+
+// GetErrorField returns the input err is about, empty if unknown
+func GetErrorField(err error) string {
+	var typedErr Error
+	if errors.As(err, &typedErr) {
+		return typedErr.Field
+	}
+	return ""
+}
+
+// asInvalidArgument classifies err as an InvalidArgumentError about field
+// unless it is already classified
+func asInvalidArgument(field string, err error) error {
+	var typedErr Error
+	if err == nil || errors.As(err, &typedErr) {
+		return err
+	}
+	return Error{InvalidArgumentError, field, err}
+}
@@ -0,0 +1,66 @@
+package util
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func assertErrorKind(err error, expectedKind ErrorKind, expectedField string, t *testing.T) {
+	if err == nil {
+		t.Errorf("Should have a %s error about '%s'", expectedKind, expectedField)
+		return
+	}
+	if kind, field := GetErrorKind(err), GetErrorField(err); kind != expectedKind || field != expectedField {
+		t.Errorf("Error '%s' should be %s about '%s' instead of %s about '%s'", err, expectedKind, expectedField, kind, field)
+	}
+}
+
+func TestUnknownCityIsNotFound(t *testing.T) {
+	_, err := utils.GetTime("now", "Dummy")
+	assertErrorKind(err, NotFoundError, "city", t)
+	_, err = timeDataUtils.GetCityCode("Dummy")
+	assertErrorKind(err, NotFoundError, "city", t)
+}
+
+func TestMalformedInputsAreInvalidArguments(t *testing.T) {
+	_, err := utils.GetTime("2015-04-02T25:00:00", "PAR")
+	assertErrorKind(err, InvalidArgumentError, "date", t)
+	_, err = utils.GetTime("last week", "PAR")
+	assertErrorKind(err, InvalidArgumentError, "date", t)
+	_, err = utils.WithDSTPolicy(DSTReject).GetTime("2015-03-29T02:30:00", "PAR")
+	assertErrorKind(err, InvalidArgumentError, "date", t)
+	_, err = utils.GetDatesForPeriod(context.Background(), time.Now(), "3X")
+	assertErrorKind(err, InvalidArgumentError, "duration", t)
+	_, err = ParseDSTPolicy("nimportequoi")
+	assertErrorKind(err, InvalidArgumentError, "dst", t)
+	_, err = LoadDisplayLocation("Mars/Olympus")
+	assertErrorKind(err, InvalidArgumentError, "tz", t)
+}
+
+func TestMalformedStoredDataIsDataError(t *testing.T) {
+	_, err := NewDataUtilsFromJSON([]byte("not JSON"), t.TempDir())
+	assertErrorKind(err, DataError, "", t)
+
+	citiesJSON, _ := os.ReadFile("../resources/cities.json")
+	dataDir := t.TempDir()
+	os.WriteFile(filepath.Join(dataDir, "PAR.json"), []byte("not JSON"), 0644)
+	dataUtils, _ := NewDataUtilsFromJSON(citiesJSON, dataDir)
+	_, err = dataUtils.GetStoredTemps("PAR")
+	assertErrorKind(err, DataError, "", t)
+}
+
+func TestWrappedErrorsKeepTheirKind(t *testing.T) {
+	err := fmt.Errorf("Cannot print temps: %w", NotFoundErrorf("city", "Have not found city %s", "Dummy"))
+	assertErrorKind(err, NotFoundError, "city", t)
+}
+
+func TestUnclassifiedErrorsAreInternal(t *testing.T) {
+	err := fmt.Errorf("Disk is full")
+	if GetErrorKind(err) != InternalError || GetErrorField(err) != "" {
+		t.Errorf("Unclassified error should be internal without field")
+	}
+}
@@ -0,0 +1,56 @@
+package util
+
+import (
+	"math"
+	"math/rand"
+	"strings"
+	"time"
+)
+
+// GeneratorModel tells how temps are generated from the range of the city
+// sample for the date
+type GeneratorModel int
+
+const (
+	// UniformModel picks any temp of the sample range, slightly widened,
+	// whatever the time of day
+	UniformModel GeneratorModel = iota
+	// DiurnalModel follows the daily cycle, coldest at 3h and warmest at 15h
+	// local time, with one degree of noise
+	DiurnalModel
+)
+
+var generatorModelNames = []string{"uniform", "diurnal"}
+
+// GeneratorModels lists the names of available generator models
+var GeneratorModels = strings.Join(generatorModelNames, ", ")
+
+// ParseGeneratorModel returns the generator model named uniform or diurnal
+func ParseGeneratorModel(name string) (GeneratorModel, error) {
+	for model, modelName := range generatorModelNames {
+		if strings.EqualFold(modelName, name) {
+			return GeneratorModel(model), nil
+		}
+	}
+	return UniformModel, InvalidArgumentErrorf("model", "Unknown generator model '%s'. Expecting one of %s", name, GeneratorModels)
+}
+
+func (model GeneratorModel) String() string {
+	return generatorModelNames[model]
+}
+
+func (model GeneratorModel) generate(min, max int, requestTime time.Time, providerRand *rand.Rand) int {
+	if model == DiurnalModel {
+		hour := float64(requestTime.Hour()) + float64(requestTime.Minute())/60
+		dayPosition := (1 - math.Cos(2*math.Pi*(hour-3)/24)) / 2
+		temp := float64(min) + dayPosition*float64(max-min) + float64(providerRand.Intn(3)-1)
+		return int(math.Floor(temp + 0.5))
+	}
+	loTemp := min - providerRand.Intn(2)
+	diff := max + providerRand.Intn(3) - loTemp
+	if diff <= 0 {
+		// Ranges of a cities file edited by hand may be empty
+		return loTemp
+	}
+	return loTemp + providerRand.Intn(diff)
+}
@@ -0,0 +1,279 @@
+package util
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ConflictPolicy tells what importing an observation does when a different
+// one is already stored for the same time. Generated temps are always
+// replaced by observations
+type ConflictPolicy int
+
+const (
+	// ConflictSkip keeps the stored observation
+	ConflictSkip ConflictPolicy = iota
+	// ConflictOverwrite replaces the stored observation
+	ConflictOverwrite
+	// ConflictFail fails the import
+	ConflictFail
+)
+
+var conflictPolicyNames = []string{"skip", "overwrite", "fail"}
+
+// ConflictPolicies lists the names of available conflict policies
+var ConflictPolicies = strings.Join(conflictPolicyNames, ", ")
+
+// ParseConflictPolicy returns the conflict policy named skip, overwrite or
+// fail
+func ParseConflictPolicy(name string) (ConflictPolicy, error) {
+	for policy, policyName := range conflictPolicyNames {
+		if strings.EqualFold(policyName, name) {
+			return ConflictPolicy(policy), nil
+		}
+	}
+	return ConflictSkip, InvalidArgumentErrorf("on-conflict", "Unknown conflict policy '%s'. Expecting one of %s", name, ConflictPolicies)
+}
+
+func (policy ConflictPolicy) String() string {
+	return conflictPolicyNames[policy]
+}
+
+// ImportReport counts what importing the observations of a city does
+type ImportReport struct {
+	City string `json:"city"`
+	// Read observations, Added ones for new times and the ones which Replaced
+	// generated temps
+	Read     int `json:"read"`
+	Added    int `json:"added"`
+	Replaced int `json:"replaced"`
+	// Unchanged observations were already stored. Conflicting ones were
+	// Overwritten or Skipped
+	Unchanged   int `json:"unchanged"`
+	Overwritten int `json:"overwritten"`
+	Skipped     int `json:"skipped"`
+	// Rounded observations were not on the hour and are imported at the
+	// closest one, as temps are looked up by the hour
+	Rounded int `json:"rounded"`
+}
+
+// CSVMapping tells where observations are in CSV records and how to read them
+// Columns are header names or 1-based indexes. City, when set, is the city of
+// every record instead of the one of CityColumn. Times without offset are
+// read in Location, or in the time zone of their city if nil
+type CSVMapping struct {
+	TimeColumn, CityColumn, TempColumn string
+	City                               string
+	Unit                               TempUnit
+	Location                           *time.Location
+	Comma                              rune
+}
+
+// observationTimeLayouts are the layouts of times read in CSV records, with
+// and without offset
+var observationTimeLayouts = []string{time.RFC3339Nano, "2006-01-02 15:04:05Z07:00", "2006-01-02T15:04Z07:00", "2006-01-02 15:04Z07:00"}
+var observationLocalTimeLayouts = []string{"2006-01-02T15:04:05", "2006-01-02 15:04:05", "2006-01-02T15:04", "2006-01-02 15:04"}
+
+var unixTimePattern = regexp.MustCompile(`^-?\d+$`)
+
+// ReadObservationsCSV returns observed temps by city code of CSV records
+// following a header line. Temps are rounded to the closest degree
+func (utils DataUtils) ReadObservationsCSV(reader io.Reader, mapping CSVMapping) (map[string][]Temp, error) {
+	csvReader := csv.NewReader(reader)
+	if mapping.Comma != 0 {
+		csvReader.Comma = mapping.Comma
+	}
+	csvReader.TrimLeadingSpace = true
+	header, err := csvReader.Read()
+	if err == io.EOF {
+		return nil, InvalidArgumentErrorf("file", "Expecting a header line")
+	}
+	if err != nil {
+		return nil, InvalidArgumentErrorf("file", "Cannot read CSV: %s", err)
+	}
+	timeIndex, err := columnIndex(header, mapping.TimeColumn, "time-column")
+	if err != nil {
+		return nil, err
+	}
+	tempIndex, err := columnIndex(header, mapping.TempColumn, "temp-column")
+	if err != nil {
+		return nil, err
+	}
+	cityIndex := -1
+	if mapping.City == "" {
+		if cityIndex, err = columnIndex(header, mapping.CityColumn, "city-column"); err != nil {
+			return nil, err
+		}
+	}
+
+	observations := make(map[string][]Temp)
+	locations := make(map[string]*time.Location)
+	for {
+		record, err := csvReader.Read()
+		if err == io.EOF {
+			return observations, nil
+		}
+		if err != nil {
+			return nil, InvalidArgumentErrorf("file", "Cannot read CSV: %s", err)
+		}
+		line, _ := csvReader.FieldPos(0)
+		city := mapping.City
+		if cityIndex >= 0 {
+			city = record[cityIndex]
+		}
+		cityCode, err := utils.GetCityCode(strings.TrimSpace(city))
+		if err != nil {
+			return nil, fmt.Errorf("Line %d: %w", line, err)
+		}
+		location, found := locations[cityCode]
+		if !found {
+			if location, err = utils.getCityLocation(cityCode); err != nil {
+				return nil, err
+			}
+			locations[cityCode] = location
+		}
+		if mapping.Location != nil {
+			location = mapping.Location
+		}
+		observedTime, err := parseObservationTime(strings.TrimSpace(record[timeIndex]), location)
+		if err != nil {
+			return nil, InvalidArgumentErrorf("file", "Line %d: %s", line, err)
+		}
+		temp, err := strconv.ParseFloat(strings.TrimSpace(record[tempIndex]), 64)
+		if err != nil {
+			return nil, InvalidArgumentErrorf("file", "Line %d: temp '%s' should be a number", line, record[tempIndex])
+		}
+		observations[cityCode] = append(observations[cityCode], Temp{observedTime.In(locations[cityCode]), Celsius.convert(temp, mapping.Unit), true})
+	}
+}
+
+// columnIndex returns the index of column, a name of header or a 1-based
+// index, the flag column comes from being field
+func columnIndex(header []string, column, field string) (int, error) {
+	if index, err := strconv.Atoi(column); err == nil {
+		if index < 1 || index > len(header) {
+			return -1, InvalidArgumentErrorf(field, "Column %d is out of the %d columns of CSV records", index, len(header))
+		}
+		return index - 1, nil
+	}
+	for index, name := range header {
+		if strings.EqualFold(strings.TrimSpace(name), column) {
+			return index, nil
+		}
+	}
+	return -1, InvalidArgumentErrorf(field, "No column '%s' in CSV header %s", column, strings.Join(header, ", "))
+}
+
+// parseObservationTime reads Unix seconds and times with or without offset,
+// the ones without offset being local times of location
+func parseObservationTime(value string, location *time.Location) (time.Time, error) {
+	if unixTimePattern.MatchString(value) {
+		seconds, err := strconv.ParseInt(value, 10, 64)
+		return time.Unix(seconds, 0), err
+	}
+	for _, layout := range observationTimeLayouts {
+		if parsed, err := time.Parse(layout, value); err == nil {
+			return parsed, nil
+		}
+	}
+	for _, layout := range observationLocalTimeLayouts {
+		if wallClock, err := time.Parse(layout, value); err == nil {
+			return DSTEarliest.resolve(wallClock, location)
+		}
+	}
+	return timeNil, fmt.Errorf("time '%s' should be like 2006-01-02T15:04:05, with an offset or not, or Unix seconds", value)
+}
+
+// ImportObservations stores observed temps of city at the closest hour. They
+// replace generated temps stored for the same time and policy tells what to
+// do with different observations already stored. Nothing is stored on dry run
+func (utils DataUtils) ImportObservations(city string, observations []Temp, policy ConflictPolicy, dryRun bool) (ImportReport, error) {
+	report := ImportReport{city, len(observations), 0, 0, 0, 0, 0, 0}
+	cityFile, err := utils.getCityFileName(city)
+	if err != nil {
+		return report, err
+	}
+	location, err := utils.getCityLocation(city)
+	if err != nil {
+		return report, err
+	}
+
+	utils.cache.lock.Lock()
+	defer utils.cache.lock.Unlock()
+	stored, err := utils.loadTemps(cityFile)
+	if err != nil {
+		return report, err
+	}
+	// Stored temps change only once every observation is imported
+	imported := &storedTemps{append(temps{}, stored.temps...), make(map[int64]int, len(stored.byTime))}
+	for unixTime, index := range stored.byTime {
+		imported.byTime[unixTime] = index
+	}
+	for _, observation := range observations {
+		observation.Observed = true
+		if hour := roundToHour(observation.Time.In(location)); !hour.Equal(observation.Time) {
+			observation.Time = hour
+			report.Rounded++
+		}
+		index, found := imported.byTime[observation.Time.Unix()]
+		switch {
+		case !found:
+			imported.byTime[observation.Time.Unix()] = len(imported.temps)
+			imported.temps = append(imported.temps, observation)
+			report.Added++
+		case !imported.temps[index].Observed:
+			imported.temps[index] = observation
+			report.Replaced++
+		case imported.temps[index].Temp == observation.Temp:
+			report.Unchanged++
+		case policy == ConflictOverwrite:
+			imported.temps[index] = observation
+			report.Overwritten++
+		case policy == ConflictFail:
+			return report, InvalidArgumentErrorf("temp", "Observed temp of %s at %s is %d but %d is already stored",
+				city, observation.Time.Format(TimeFormat), observation.Temp, imported.temps[index].Temp)
+		default:
+			report.Skipped++
+		}
+	}
+	if dryRun || report.Added+report.Replaced+report.Overwritten == 0 {
+		return report, nil
+	}
+	if err = os.MkdirAll(path.Dir(cityFile), 0755); err != nil {
+		return report, err
+	}
+	if err = writeJSONFileAtomically(cityFile, imported.temps); err != nil {
+		return report, err
+	}
+	*stored = *imported
+	return report, nil
+}
+
+// roundToHour returns the local hour of location closest to observedTime
+func roundToHour(observedTime time.Time) time.Time {
+	hour := getTimeWithoutMinuteSecondNano(observedTime)
+	if observedTime.Sub(hour) >= 30*time.Minute {
+		return getTimeWithoutMinuteSecondNano(hour.Add(time.Hour))
+	}
+	return hour
+}
+
+// getCityLocation returns the time zone of the city with code
+func (utils DataUtils) getCityLocation(code string) (*time.Location, error) {
+	citiesData, err := utils.getCitiesData()
+	if err != nil {
+		return nil, err
+	}
+	index, err := citiesData.find(code)
+	if err != nil {
+		return nil, err
+	}
+	return time.LoadLocation(citiesData[index].IanaTZ)
+}
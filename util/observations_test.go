@@ -0,0 +1,104 @@
+package util
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newObservationsDataUtils(t *testing.T) DataUtils {
+	citiesJSON, _ := os.ReadFile("../resources/cities.json")
+	dataUtils, err := NewDataUtilsFromJSON(citiesJSON, t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dataUtils
+}
+
+func TestReadObservationsCSVWithMappingUnitAndTimeZones(t *testing.T) {
+	dataUtils := newObservationsDataUtils(t)
+	csv := "station;when;fahrenheit\nParis;2015-04-02 17:00;50\nnyc;2015-04-02T17:00:00Z;32.6\nNYC;1428000000;-40\n"
+	observations, err := dataUtils.ReadObservationsCSV(strings.NewReader(csv), CSVMapping{"when", "1", "fahrenheit", "", Fahrenheit, nil, ';'})
+	if err != nil {
+		t.Fatal(err)
+	}
+	paris, newYork := observations["PAR"], observations["NYC"]
+	if len(paris) != 1 || paris[0].Time.Format(TimeFormat) != "2015-04-02T17:00:00+02:00" || paris[0].Temp != 10 || !paris[0].Observed {
+		t.Errorf("Paris observation should be in Paris time zone and Celsius: %v", paris)
+	}
+	if len(newYork) != 2 || newYork[0].Time.Format(TimeFormat) != "2015-04-02T13:00:00-04:00" || newYork[0].Temp != 0 || newYork[1].Temp != -40 {
+		t.Errorf("New York observations should be in New York time zone and Celsius: %v", newYork)
+	}
+}
+
+func TestReadObservationsCSVReportsLines(t *testing.T) {
+	dataUtils := newObservationsDataUtils(t)
+	for csv, expectedField := range map[string]string{
+		"timestamp,city,temperature\n2015-04-02T17:00:00,Paris,12\n2015-04-02T18:00:00,Paris,hot\n": "file",
+		"timestamp,city,temperature\n2015-04-02T17:00:00,Atlantis,12\n":                             "city",
+		"time,city,temperature\n2015-04-02T17:00:00,Paris,12\n":                                     "time-column",
+	} {
+		_, err := dataUtils.ReadObservationsCSV(strings.NewReader(csv), CSVMapping{"timestamp", "city", "temperature", "", Celsius, nil, 0})
+		if GetErrorField(err) != expectedField {
+			t.Errorf("Should have an error about %s instead of '%v' for\n%s", expectedField, err, csv)
+		}
+	}
+	_, err := dataUtils.ReadObservationsCSV(strings.NewReader("timestamp,city,temperature\n2015-04-02T17:00:00,Paris,12\nlast week,Paris,13\n"),
+		CSVMapping{"timestamp", "city", "temperature", "", Celsius, nil, 0})
+	if err == nil || !strings.Contains(err.Error(), "Line 3") {
+		t.Errorf("Error should tell the line instead of '%v'", err)
+	}
+}
+
+func TestObservationsReplaceGeneratedTempsAndFollowConflictPolicy(t *testing.T) {
+	dataUtils := newObservationsDataUtils(t)
+	paris := time.Date(2015, 4, 2, 17, 0, 0, 0, time.UTC)
+	dataUtils.saveTemps("PAR", temps{Temp{paris, 20, false}, Temp{paris.Add(time.Hour), 21, false}})
+	observations := []Temp{{paris, 12, true}, {paris.Add(2 * time.Hour), 11, true}}
+
+	report, err := dataUtils.ImportObservations("PAR", observations, ConflictFail, true)
+	if err != nil || report != (ImportReport{"PAR", 2, 1, 1, 0, 0, 0, 0}) {
+		t.Errorf("Unexpected dry run report %+v, error '%v'", report, err)
+	}
+	if temp, _ := dataUtils.getTemp("PAR", paris); temp != 20 {
+		t.Errorf("Dry run should not store anything but temp is %d", temp)
+	}
+	if _, err = dataUtils.ImportObservations("PAR", observations, ConflictFail, false); err != nil {
+		t.Fatal(err)
+	}
+	if temp, _ := dataUtils.getTemp("PAR", paris); temp != 12 {
+		t.Errorf("Observation should replace generated temp but temp is %d", temp)
+	}
+
+	conflicting := []Temp{{paris, 13, true}, {paris.Add(2 * time.Hour), 11, true}}
+	report, _ = dataUtils.ImportObservations("PAR", conflicting, ConflictSkip, false)
+	if report != (ImportReport{"PAR", 2, 0, 0, 1, 0, 1, 0}) {
+		t.Errorf("Unexpected skip report %+v", report)
+	}
+	_, err = dataUtils.ImportObservations("PAR", conflicting, ConflictFail, false)
+	assertErrorKind(err, InvalidArgumentError, "temp", t)
+	report, _ = dataUtils.ImportObservations("PAR", conflicting, ConflictOverwrite, false)
+	if temp, _ := dataUtils.getTemp("PAR", paris); temp != 13 || report.Overwritten != 1 {
+		t.Errorf("Observation should be overwritten but temp is %d, report %+v", temp, report)
+	}
+}
+
+func TestObservationsAreImportedAtTheClosestHour(t *testing.T) {
+	dataUtils := newObservationsDataUtils(t)
+	paris := time.Date(2015, 4, 2, 17, 0, 0, 0, time.UTC)
+	observations := []Temp{{paris.Add(29 * time.Minute), 12, true}, {paris.Add(90 * time.Minute), 14, true}, {paris.Add(3 * time.Hour), 15, true}}
+
+	report, err := dataUtils.ImportObservations("PAR", observations, ConflictFail, true)
+	if err != nil || report != (ImportReport{"PAR", 3, 3, 0, 0, 0, 0, 2}) {
+		t.Errorf("Dry run should report 2 rounded observations instead of %+v, error '%v'", report, err)
+	}
+	if _, err = dataUtils.ImportObservations("PAR", observations, ConflictFail, false); err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []Temp{{paris, 12, true}, {paris.Add(2 * time.Hour), 14, true}, {paris.Add(3 * time.Hour), 15, true}} {
+		if temp, err := dataUtils.getTemp("PAR", expected.Time); err != nil || temp != expected.Temp {
+			t.Errorf("Temp at %s should be %d instead of %d, error '%v'", expected.Time, expected.Temp, temp, err)
+		}
+	}
+}
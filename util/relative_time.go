@@ -0,0 +1,182 @@
+package util
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// RelativeTimeExamples lists relative expressions understood by GetTime
+const RelativeTimeExamples = "now, today 15h, yesterday, -3d, last monday 08:00 or 2015-04-02"
+
+var dateOnlyRegexp *regexp.Regexp
+var offsetRegexp *regexp.Regexp
+var clockRegexp *regexp.Regexp
+
+func compileRelativeTimeRegexps() error {
+	var error error
+	dateOnlyRegexp, error = regexp.Compile("^\\d{4}-\\d{2}-\\d{2}$")
+	if error != nil {
+		return error
+	}
+	offsetRegexp, error = regexp.Compile("^([+-])(\\d+)([hdwmy])$")
+	if error != nil {
+		return error
+	}
+	clockRegexp, error = regexp.Compile("^(\\d{1,2})(?:h(\\d{2})?|:(\\d{2})(?::(\\d{2}))?)$")
+	return error
+}
+
+var weekdays = map[string]time.Weekday{
+	"sunday":    time.Sunday,
+	"monday":    time.Monday,
+	"tuesday":   time.Tuesday,
+	"wednesday": time.Wednesday,
+	"thursday":  time.Thursday,
+	"friday":    time.Friday,
+	"saturday":  time.Saturday,
+}
+
+// getRelativeTime evaluates expressions like "now", "today 15h", "yesterday",
+// "-3d", "last monday 08:00" or "2015-04-02" in the provided location.
+// The boolean is false when the expression is not a relative one so the
+// caller can try other formats.
+// Day expressions without time of day are at midnight, offsets keep the
+// current time of day unless one is provided. Local times are resolved with
+// the provided DST policy.
+func getRelativeTime(expression string, now time.Time, location *time.Location, policy DSTPolicy) (time.Time, bool, error) {
+	words := strings.Fields(strings.ToLower(expression))
+	if len(words) == 0 {
+		return timeNil, false, nil
+	}
+	// Calendar arithmetic is done on wall clocks so that DST changes are
+	// only handled when resolving the final local time
+	wallNow := getWallClock(now.In(location))
+	today := time.Date(wallNow.Year(), wallNow.Month(), wallNow.Day(), 0, 0, 0, 0, time.UTC)
+
+	var wallBase time.Time
+	var instantBase *time.Time
+	first, rest := words[0], words[1:]
+	switch {
+	case first == "now":
+		instantBase = &now
+	case first == "today":
+		wallBase = today
+	case first == "yesterday":
+		wallBase = today.AddDate(0, 0, -1)
+	case first == "tomorrow":
+		wallBase = today.AddDate(0, 0, 1)
+	case first == "last" || first == "next":
+		if len(rest) == 0 {
+			return timeNil, true, fmt.Errorf("Expecting a week day after '%s' in '%s'", first, expression)
+		}
+		weekday, isWeekday := weekdays[rest[0]]
+		if !isWeekday {
+			return timeNil, true, fmt.Errorf("'%s' is not a week day in '%s'", rest[0], expression)
+		}
+		wallBase, rest = getWeekday(today, weekday, first == "next"), rest[1:]
+	case isWeekday(first):
+		wallBase = getWeekdayOnOrBefore(today, weekdays[first])
+	case dateOnlyRegexp.MatchString(first):
+		date, err := time.ParseInLocation("2006-01-02", first, time.UTC)
+		if err != nil {
+			return timeNil, true, fmt.Errorf("'%s' is not a valid date in '%s'", first, expression)
+		}
+		wallBase = date
+	case offsetRegexp.MatchString(first):
+		offsetParts := offsetRegexp.FindStringSubmatch(first)
+		if offsetParts[3] == "h" {
+			offsetTime := applyOffset(now, offsetParts)
+			instantBase = &offsetTime
+		} else {
+			wallBase = applyOffset(wallNow, offsetParts)
+		}
+	default:
+		return timeNil, false, nil
+	}
+
+	switch len(rest) {
+	case 0:
+		if instantBase != nil {
+			return instantBase.In(location), true, nil
+		}
+		resolvedTime, err := policy.resolve(wallBase, location)
+		return resolvedTime, true, err
+	case 1:
+		if first == "now" {
+			return timeNil, true, fmt.Errorf("'now' cannot be followed by a time of day in '%s'", expression)
+		}
+		hour, minute, second, ok := parseClock(rest[0])
+		if !ok {
+			return timeNil, true, fmt.Errorf("'%s' is not a time of day like 15h, 15h30 or 08:00 in '%s'", rest[0], expression)
+		}
+		if instantBase != nil {
+			wallBase = getWallClock(instantBase.In(location))
+		}
+		wallTime := time.Date(wallBase.Year(), wallBase.Month(), wallBase.Day(), hour, minute, second, 0, time.UTC)
+		resolvedTime, err := policy.resolve(wallTime, location)
+		return resolvedTime, true, err
+	default:
+		return timeNil, true, fmt.Errorf("Unexpected '%s' in '%s'", strings.Join(rest[1:], " "), expression)
+	}
+}
+
+func isWeekday(word string) bool {
+	_, found := weekdays[word]
+	return found
+}
+
+// getWeekday returns the closest given week day strictly before or after day
+func getWeekday(day time.Time, weekday time.Weekday, after bool) time.Time {
+	if after {
+		daysAhead := (int(weekday)-int(day.Weekday())+6)%7 + 1
+		return day.AddDate(0, 0, daysAhead)
+	}
+	daysBehind := (int(day.Weekday())-int(weekday)+6)%7 + 1
+	return day.AddDate(0, 0, -daysBehind)
+}
+
+func getWeekdayOnOrBefore(day time.Time, weekday time.Weekday) time.Time {
+	daysBehind := (int(day.Weekday()) - int(weekday) + 7) % 7
+	return day.AddDate(0, 0, -daysBehind)
+}
+
+func applyOffset(now time.Time, offsetParts []string) time.Time {
+	length, _ := parseToInt(offsetParts[2])
+	if offsetParts[1] == "-" {
+		length = -length
+	}
+	switch offsetParts[3] {
+	case "h":
+		return now.Add(time.Duration(length) * time.Hour)
+	case "d":
+		return now.AddDate(0, 0, length)
+	case "w":
+		return now.AddDate(0, 0, 7*length)
+	case "m":
+		return now.AddDate(0, length, 0)
+	default:
+		return now.AddDate(length, 0, 0)
+	}
+}
+
+func parseClock(clock string) (hour, minute, second int, ok bool) {
+	parts := clockRegexp.FindStringSubmatch(clock)
+	if parts == nil {
+		return 0, 0, 0, false
+	}
+	hour, _ = parseToInt(parts[1])
+	for _, minutePart := range parts[2:4] {
+		if minutePart != "" {
+			minute, _ = parseToInt(minutePart)
+		}
+	}
+	if parts[4] != "" {
+		second, _ = parseToInt(parts[4])
+	}
+	if hour > 23 || minute > 59 || second > 59 {
+		return 0, 0, 0, false
+	}
+	return hour, minute, second, true
+}
@@ -0,0 +1,70 @@
+package util
+
+import (
+	"testing"
+	"time"
+)
+
+// Thursday 2015-04-02 17:42:10 in Paris
+var relativeNow = time.Date(2015, 4, 2, 15, 42, 10, 0, time.UTC)
+
+func relativeTimeUtils() TimeUtils {
+	relativeUtils := utils
+	relativeUtils.now = func() time.Time { return relativeNow }
+	return relativeUtils
+}
+
+func assertRelativeTime(expression, city, expectedTime string, t *testing.T) {
+	actualTime, err := relativeTimeUtils().GetTime(expression, city)
+	if err != nil {
+		t.Errorf("Should not have an error for '%s' '%s'", expression, err)
+		return
+	}
+	actualFormattedTime := actualTime.Format(TimeFormat)
+	if expectedTime != actualFormattedTime {
+		t.Errorf("Expected time '%s' for '%s' is different from the actual one '%s'", expectedTime, expression,
+			actualFormattedTime)
+	}
+}
+
+func TestGetTimeNowIsInCityTimeZone(t *testing.T) {
+	assertRelativeTime("now", "PAR", "2015-04-02T17:00:00+02:00", t)
+	assertRelativeTime("now", "NYC", "2015-04-02T11:00:00-04:00", t)
+	assertRelativeTime(" NOW ", "DKR", "2015-04-02T15:00:00Z", t)
+}
+
+func TestGetTimeWithDayKeywords(t *testing.T) {
+	assertRelativeTime("today", "PAR", "2015-04-02T00:00:00+02:00", t)
+	assertRelativeTime("today 15h", "PAR", "2015-04-02T15:00:00+02:00", t)
+	assertRelativeTime("yesterday", "PAR", "2015-04-01T00:00:00+02:00", t)
+	assertRelativeTime("tomorrow 08:30", "NYC", "2015-04-03T08:00:00-04:00", t)
+}
+
+func TestGetTimeWithOffsets(t *testing.T) {
+	assertRelativeTime("-3d", "PAR", "2015-03-30T17:00:00+02:00", t)
+	assertRelativeTime("+2h", "PAR", "2015-04-02T19:00:00+02:00", t)
+	assertRelativeTime("-1w 10h", "PAR", "2015-03-26T10:00:00+01:00", t)
+	assertRelativeTime("-1m", "DKR", "2015-03-02T15:00:00Z", t)
+	assertRelativeTime("-1y", "DKR", "2014-04-02T15:00:00Z", t)
+}
+
+func TestGetTimeWithWeekDays(t *testing.T) {
+	assertRelativeTime("last monday 08:00", "PAR", "2015-03-30T08:00:00+02:00", t)
+	assertRelativeTime("last thursday", "PAR", "2015-03-26T00:00:00+01:00", t)
+	assertRelativeTime("thursday", "PAR", "2015-04-02T00:00:00+02:00", t)
+	assertRelativeTime("next thursday 15h", "PAR", "2015-04-09T15:00:00+02:00", t)
+}
+
+func TestGetTimeWithDateOnly(t *testing.T) {
+	assertRelativeTime("2015-04-02", "NYC", "2015-04-02T00:00:00-04:00", t)
+	assertRelativeTime("2015-04-02 17:00", "NYC", "2015-04-02T17:00:00-04:00", t)
+}
+
+func TestGetTimeWithInvalidRelativeExpressionsShouldFail(t *testing.T) {
+	for _, expression := range []string{"last", "last week", "today 25h", "now 15h", "yesterday 15h extra", "2015-13-02"} {
+		_, err := relativeTimeUtils().GetTime(expression, "PAR")
+		if err == nil {
+			t.Errorf("Should have an error for '%s'", expression)
+		}
+	}
+}
@@ -1,9 +1,13 @@
 package util
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
 	"math"
-	"math/rand"
+	"runtime"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -11,10 +15,13 @@ import (
 type TempTime time.Time
 
 // CityTemp represents a temperature for a given city at a given time
+// UTCOffset is the offset of the city time zone resolved for this time
 type CityTemp struct {
-	City string
-	Time TempTime
-	Temp int
+	City      string
+	Time      TempTime
+	UTCOffset string
+	Temp      int
+	Unit      TempUnit
 }
 
 // CityTemps is a set of temps with complementary information
@@ -23,26 +30,62 @@ type CityTemps struct {
 	Min, Max, Average int
 }
 
+// DateFailure tells why temperature could not be provided at a given time
+type DateFailure struct {
+	Time TempTime
+	Err  error
+}
+
+// DatesError lists times for which temperature could not be provided in a city
+type DatesError struct {
+	City     string
+	Failures []DateFailure
+}
+
 // TempProvider is the component used to get temperatures
 type TempProvider struct {
 	// INHERITANCE BY COMPOSITION
 	DataUtils
-	rand *rand.Rand
+	seed    int64
+	workers int
+	model   GeneratorModel
 }
 
 const timeToStringFormat = time.RFC1123
 
-var nilSample = sample{}
 var id = 0
 
 // NewTempProvider is the constructor for TempProvider
 func NewTempProvider(dataUtils DataUtils) (TempProvider, error) {
-	tempProvider := TempProvider{DataUtils: dataUtils}
-	tempProvider.rand = rand.New(rand.NewSource(time.Now().Unix()))
+	tempProvider := TempProvider{DataUtils: dataUtils, workers: runtime.NumCPU()}
+	tempProvider.seed = time.Now().Unix()
 	id++
 	return tempProvider, nil
 }
 
+// WithSeed returns a TempProvider generating temps from the provided seed
+// Same seed, city and time always generate the same temp
+func (tempProvider TempProvider) WithSeed(seed int64) TempProvider {
+	tempProvider.seed = seed
+	return tempProvider
+}
+
+// WithModel returns a TempProvider generating temps with the provided model
+func (tempProvider TempProvider) WithModel(model GeneratorModel) TempProvider {
+	tempProvider.model = model
+	return tempProvider
+}
+
+// WithWorkers returns a TempProvider generating temps for periods with the
+// provided number of workers
+func (tempProvider TempProvider) WithWorkers(workers int) TempProvider {
+	if workers < 1 {
+		workers = 1
+	}
+	tempProvider.workers = workers
+	return tempProvider
+}
+
 // Get provides temperature in a given city at a given time. It generates
 // and stores it locally if it doesn't exist otherwise it returns the stored
 // value
@@ -62,15 +105,50 @@ func (tempProvider TempProvider) Get(city string, requestTime time.Time) (int, e
 	return temp, nil
 }
 
+// NewCityTemp creates a CityTemp for a time in the city time zone
+func NewCityTemp(city string, cityTime time.Time, temp int) CityTemp {
+	return CityTemp{city, TempTime(cityTime), cityTime.Format("-07:00"), temp, Celsius}
+}
+
 // GetForDates provides temperature in a given city for dates returned by time slice channel
 // It uses Get underneath
-func (tempProvider TempProvider) GetForDates(city string, timesChan chan []time.Time) CityTemps {
+// Temps are not generated anymore once ctx is cancelled or its deadline
+// exceeded, the ones already generated are stored and the ones already
+// provided are returned with ctx error
+// When some temps cannot be provided, the others are returned with a
+// DatesError telling which times failed and why
+func (tempProvider TempProvider) GetForDates(ctx context.Context, city string, timesChan chan []time.Time) (CityTemps, error) {
+	var allTemps []CityTemp
+	cityTemps, err := tempProvider.streamForDates(ctx, city, timesChan, storageBatchSize, func(temps []CityTemp) error {
+		allTemps = append(allTemps, temps...)
+		return nil
+	})
+	cityTemps.Temps = allTemps
+	return cityTemps, err
+}
+
+// StreamForDates provides temperatures like GetForDates but hands them to
+// consume as soon as a batch of times received from timesChan is provided,
+// instead of keeping them
+// Returned CityTemps only has statistics of consumed temps
+// Generation stops when consume fails and its error is returned. timesChan is
+// then drained so that its producer does not stay blocked
+func (tempProvider TempProvider) StreamForDates(ctx context.Context, city string, timesChan chan []time.Time, consume func([]CityTemp) error) (CityTemps, error) {
+	return tempProvider.streamForDates(ctx, city, timesChan, 1, consume)
+}
+
+// streamForDates hands temps to consume once at least batchSize of them are
+// provided, generated ones being stored by batch
+func (tempProvider TempProvider) streamForDates(ctx context.Context, city string, timesChan chan []time.Time, batchSize int, consume func([]CityTemp) error) (CityTemps, error) {
+	generationCtx, cancelGeneration := context.WithCancel(ctx)
+	defer cancelGeneration()
 	tempsChan := make(chan []CityTemp)
+	var failures []DateFailure
 	go func() {
 		defer func() {
 			close(tempsChan)
 		}()
-		tempProvider.getForDates(city, timesChan, tempsChan)
+		failures = tempProvider.getForDates(generationCtx, city, timesChan, batchSize, tempsChan)
 	}()
 
 	cityTemps := CityTemps{}
@@ -78,8 +156,18 @@ func (tempProvider TempProvider) GetForDates(city string, timesChan chan []time.
 	nbTemps := 0
 	cityTemps.Min, cityTemps.Max = math.MaxInt32, math.MinInt32
 	avg := 0.
+	var consumeErr error
 
 	for open {
+		if consumeErr == nil {
+			consumeErr = consume(temps)
+		}
+		if consumeErr != nil {
+			// Remaining temps are only drained until generation stops
+			cancelGeneration()
+			temps, open = <-tempsChan
+			continue
+		}
 		nbNewTemps := len(temps)
 		newMin, newMax, newAvg := tempProvider.stats(temps)
 		avg = (float64(nbTemps)*avg + float64(nbNewTemps)*newAvg) / float64(nbTemps+nbNewTemps)
@@ -89,13 +177,26 @@ func (tempProvider TempProvider) GetForDates(city string, timesChan chan []time.
 		if newMax > cityTemps.Max {
 			cityTemps.Max = newMax
 		}
-		cityTemps.Temps = append(cityTemps.Temps, temps...)
 
 		nbTemps += nbNewTemps
 		temps, open = <-tempsChan
 	}
+	if consumeErr != nil || ctx.Err() != nil {
+		for range timesChan {
+		}
+	}
 	cityTemps.Average = int(avg)
-	return cityTemps
+	if consumeErr != nil {
+		return cityTemps, consumeErr
+	}
+	if err := ctx.Err(); err != nil {
+		return cityTemps, err
+	}
+	// failures are written before tempsChan is closed
+	if len(failures) > 0 {
+		return cityTemps, DatesError{city, failures}
+	}
+	return cityTemps, nil
 }
 
 func (tempProvider TempProvider) stats(temps []CityTemp) (min int, max int, avg float64) {
@@ -113,51 +214,36 @@ func (tempProvider TempProvider) stats(temps []CityTemp) (min int, max int, avg
 	return min, max, average
 }
 
-func (tempProvider TempProvider) getForDates(city string, timesChan chan []time.Time, tempsChan chan []CityTemp) {
-	times, open := <-timesChan
-	var temps []CityTemp
-	var err error
-	var temp int
-
-	for open {
-		temps = make([]CityTemp, 0, len(times))
-		for _, time := range times {
-			temp, err = tempProvider.Get(city, time)
-			if err != nil {
-				panic(err)
-			}
-			temps = append(temps, CityTemp{city, TempTime(time), temp})
-		}
-		tempsChan <- temps
-		times, open = <-timesChan
+func (tempProvider TempProvider) generate(city string, requestTime time.Time) (int, error) {
+	tempSample, error := tempProvider.getCityData(city, requestTime)
+	if error != nil {
+		return 0, error
 	}
+	return tempProvider.generateFrom(tempSample, city, requestTime)
 }
 
-func (tempProvider *TempProvider) generate(city string, requestTime time.Time) (int, error) {
-	sample, error := tempProvider.getCityTempSample(city, requestTime)
+// generateFrom generates the temp of city at requestTime from its samples
+// without locking the cache, so that workers generate in parallel
+func (tempProvider TempProvider) generateFrom(tempSample cityData, city string, requestTime time.Time) (int, error) {
+	sample, error := getCityTempSample(tempSample, requestTime)
 	if error != nil {
 		return 0, error
 	}
 	min, max := sample.TempRange[0], sample.TempRange[1]
-	providerRand := tempProvider.rand
-	loTemp := min - providerRand.Intn(2)
-	diff := max + providerRand.Intn(3) - loTemp
-	generatedTemp := loTemp + providerRand.Intn(diff)
-	return generatedTemp, nil
+	return tempProvider.model.generate(min, max, requestTime, tempProvider.getRand(city, requestTime)), nil
 }
 
-func (tempProvider TempProvider) getCityTempSample(city string, requestTime time.Time) (sample, error) {
+func (tempProvider TempProvider) getCityData(city string, requestTime time.Time) (cityData, error) {
 	citiesData, error := tempProvider.getCitiesData()
 	if error != nil {
-		return nilSample, error
+		return cityData{}, error
 	}
 	for _, tempSample := range citiesData {
-		if tempSample.Name != city && tempSample.Code != city {
-			continue
+		if tempSample.Name == city || tempSample.Code == city {
+			return tempSample, nil
 		}
-		return getCityTempSample(tempSample, requestTime)
 	}
-	return nilSample, fmt.Errorf("Should always be able to find a sample for %s and %s", city, requestTime)
+	return cityData{}, fmt.Errorf("Should always be able to find a sample for %s and %s", city, requestTime)
 }
 
 func getCityTempSample(tempSample cityData, requestTime time.Time) (sample, error) {
@@ -189,17 +275,63 @@ func timeIn2014AtEleven(day int, requestTime time.Time) time.Time {
 
 // HOW TO FORMAT
 
+func (err DatesError) Error() string {
+	failures := make([]string, 0, len(err.Failures))
+	for _, failure := range err.Failures {
+		failures = append(failures, failure.String())
+	}
+	return fmt.Sprintf("Could not provide %d temps for %s: %s", len(err.Failures), err.City, strings.Join(failures, "; "))
+}
+
+func (failure DateFailure) String() string {
+	return failure.Time.Format(TimeFormat) + " " + failure.Err.Error()
+}
+
+// MarshalJSON provides JSON marshaller with error message
+func (failure DateFailure) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Time  TempTime
+		Error string
+	}{failure.Time, failure.Err.Error()})
+}
+
 func (obj CityTemp) String() string {
 	formattedTime := obj.Time.Format(timeToStringFormat)
 	return fmt.Sprintf("%s %s %d", obj.City, formattedTime, obj.Temp)
 }
 
+// In returns the temp with its time rendered in location
+// The UTC offset resolved in the city time zone is kept
+func (obj CityTemp) In(location *time.Location) CityTemp {
+	obj.Time = obj.Time.In(location)
+	return obj
+}
+
+// In returns the temps with their times rendered in location
+func (obj CityTemps) In(location *time.Location) CityTemps {
+	temps := make([]CityTemp, 0, len(obj.Temps))
+	for _, temp := range obj.Temps {
+		temps = append(temps, temp.In(location))
+	}
+	obj.Temps = temps
+	return obj
+}
+
+// In provides the same time rendered in location
+func (ourTime TempTime) In(location *time.Location) TempTime {
+	return TempTime(time.Time(ourTime).In(location))
+}
+
 // Format provides custom format for time
 func (ourTime TempTime) Format(format string) string {
 	return time.Time(ourTime).Format(format)
 }
 
 // MarshalJSON provides custom JSON marshaller for time
+// Times in EpochLocation are rendered as Unix seconds
 func (ourTime TempTime) MarshalJSON() ([]byte, error) {
+	if time.Time(ourTime).Location() == EpochLocation {
+		return []byte(strconv.FormatInt(time.Time(ourTime).Unix(), 10)), nil
+	}
 	return []byte("\"" + ourTime.Format(TimeFormat) + "\""), nil
 }
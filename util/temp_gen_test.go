@@ -1,6 +1,9 @@
 package util
 
 import (
+	"context"
+	"encoding/json"
+	"math/rand"
 	"os"
 	"testing"
 	"time"
@@ -88,11 +91,14 @@ func TestShouldReturnSameTempFor2CallsWithSameParametersAfterDayLightSavingTimeI
 
 func TestGetForDatesWithNormalPeriod(t *testing.T) {
 	location, _ := time.LoadLocation("Africa/Dakar")
-	timesChan, err := utils.GetDatesForPeriod(time.Date(2013, 4, 16, 10, 00, 00, 00, location), "5D")
+	timesChan, err := utils.GetDatesForPeriod(context.Background(), time.Date(2013, 4, 16, 10, 00, 00, 00, location), "5D")
+	if err != nil {
+		t.Error(err)
+	}
+	actualTemps, err := tempProvider.GetForDates(context.Background(), "DKR", timesChan)
 	if err != nil {
 		t.Error(err)
 	}
-	actualTemps := tempProvider.GetForDates("DKR", timesChan)
 	if 6 != len(actualTemps.Temps) {
 		t.Errorf("We should get exactly 6 temps for provided period %v", actualTemps)
 	}
@@ -101,17 +107,20 @@ func TestGetForDatesWithNormalPeriod(t *testing.T) {
 	}
 	min, max, avg := tempProvider.stats(actualTemps.Temps)
 	if actualTemps.Min != min || actualTemps.Max != max || actualTemps.Average != int(avg) {
-		t.Errorf("Computed min %d, max %d or avg %d should not be different from %d, %d, %d", actualTemps.Min, actualTemps.Max, actualTemps.Average, min, max, avg)
+		t.Errorf("Computed min %d, max %d or avg %d should not be different from %d, %d, %v", actualTemps.Min, actualTemps.Max, actualTemps.Average, min, max, avg)
 	}
 }
 
 func TestGetForDatesWithSingleDatePeriod(t *testing.T) {
 	location, _ := time.LoadLocation("Africa/Dakar")
-	timesChan, err := utils.GetDatesForPeriod(time.Date(2013, 4, 16, 10, 00, 00, 00, location), "0D")
+	timesChan, err := utils.GetDatesForPeriod(context.Background(), time.Date(2013, 4, 16, 10, 00, 00, 00, location), "0D")
+	if err != nil {
+		t.Error(err)
+	}
+	actualTemps, err := tempProvider.GetForDates(context.Background(), "DKR", timesChan)
 	if err != nil {
 		t.Error(err)
 	}
-	actualTemps := tempProvider.GetForDates("DKR", timesChan)
 	if 1 != len(actualTemps.Temps) {
 		t.Errorf("We should get exactly 1 temp for provided period %v", actualTemps)
 	}
@@ -134,6 +143,117 @@ func TestStats(t *testing.T) {
 		t.Errorf("Max %d should be equal to 5", max)
 	}
 	if avg != 3 {
-		t.Errorf("Average %d should be equal to 3", avg)
+		t.Errorf("Average %v should be equal to 3", avg)
+	}
+}
+
+func TestCityTempRenderedInDisplayLocation(t *testing.T) {
+	location, _ := time.LoadLocation("Europe/Paris")
+	cityTemp := NewCityTemp("PAR", time.Date(2015, 4, 2, 17, 0, 0, 0, location), 12)
+	expectedJSONs := map[string]string{
+		"UTC":              `{"City":"PAR","Time":"2015-04-02T15:00:00Z","UTCOffset":"+02:00","Temp":12,"Unit":"C"}`,
+		"America/New_York": `{"City":"PAR","Time":"2015-04-02T11:00:00-04:00","UTCOffset":"+02:00","Temp":12,"Unit":"C"}`,
+		"epoch":            `{"City":"PAR","Time":1427986800,"UTCOffset":"+02:00","Temp":12,"Unit":"C"}`,
+	}
+	for displayZone, expectedJSON := range expectedJSONs {
+		displayLocation, err := LoadDisplayLocation(displayZone)
+		if err != nil {
+			t.Errorf("Should not have an error for '%s' '%s'", displayZone, err)
+			continue
+		}
+		actualJSON, _ := json.Marshal(cityTemp.In(displayLocation))
+		if string(actualJSON) != expectedJSON {
+			t.Errorf("Expected JSON %s in %s is different from the actual one %s", expectedJSON, displayZone, actualJSON)
+		}
+	}
+}
+
+func TestLoadDisplayLocationWithUnknownZoneShouldFail(t *testing.T) {
+	if _, err := LoadDisplayLocation("Nimporte/Quoi"); err == nil {
+		t.Errorf("Should have an error for unknown time zone")
+	}
+}
+
+func TestGetForDatesStopsWhenContextIsCancelled(t *testing.T) {
+	location, _ := time.LoadLocation("Africa/Dakar")
+	ctx, cancel := context.WithCancel(context.Background())
+	timesChan, err := utils.GetDatesForPeriod(ctx, time.Date(2013, 4, 16, 10, 00, 00, 00, location), "10Y")
+	if err != nil {
+		t.Error(err)
+	}
+	cancel()
+	actualTemps, err := tempProvider.GetForDates(ctx, "DKR", timesChan)
+	if err != context.Canceled {
+		t.Errorf("Error %v should be context cancellation", err)
+	}
+	if len(actualTemps.Temps) != 0 {
+		t.Errorf("No temp should be generated once cancelled, got %d", len(actualTemps.Temps))
+	}
+}
+
+func TestGetForDatesWithMissingSamplesReturnsPartialTemps(t *testing.T) {
+	citiesFile := t.TempDir() + "/cities.json"
+	cities := `[{"name": "Nowhere", "iata_code": "NWH", "iana_timezone": "UTC",
+		"sample_temps": [{"date": "2014-01-30T11:00:00Z", "temp_range": [1, 5]}]}]`
+	if err := os.WriteFile(citiesFile, []byte(cities), 0644); err != nil {
+		t.Fatal(err)
+	}
+	dataUtils, _ := NewDataUtils(citiesFile)
+	partialTimeUtils, _ := NewTimeUtils(dataUtils)
+	partialTempProvider, _ := NewTempProvider(dataUtils)
+	timesChan, err := partialTimeUtils.GetDatesForPeriod(context.Background(), time.Date(2015, 2, 1, 10, 0, 0, 0, time.UTC), "2D")
+	if err != nil {
+		t.Error(err)
+	}
+	actualTemps, err := partialTempProvider.GetForDates(context.Background(), "NWH", timesChan)
+	datesError, isDatesError := err.(DatesError)
+	if !isDatesError {
+		t.Fatalf("Error %v should list failed dates", err)
+	}
+	if len(actualTemps.Temps) != 2 {
+		t.Errorf("We should get the 2 temps of January %v", actualTemps)
+	}
+	if len(datesError.Failures) != 1 || !time.Time(datesError.Failures[0].Time).Equal(time.Date(2015, 2, 1, 10, 0, 0, 0, time.UTC)) {
+		t.Errorf("Only February 1st should have failed %v", datesError)
+	}
+}
+
+func TestCityTempsConvertedToFahrenheit(t *testing.T) {
+	location, _ := time.LoadLocation("Europe/Paris")
+	cityTemps := CityTemps{[]CityTemp{NewCityTemp("PAR", time.Date(2015, 4, 2, 17, 0, 0, 0, location), 12),
+		NewCityTemp("PAR", time.Date(2015, 4, 3, 17, 0, 0, 0, location), -40)}, -40, 12, -14}
+	fahrenheitTemps := cityTemps.InUnit(Fahrenheit)
+	if fahrenheitTemps.Temps[0].Temp != 54 || fahrenheitTemps.Temps[1].Temp != -40 || fahrenheitTemps.Temps[0].Unit != Fahrenheit {
+		t.Errorf("Temps %v should be 54F and -40F", fahrenheitTemps.Temps)
+	}
+	if fahrenheitTemps.Min != -40 || fahrenheitTemps.Max != 54 || fahrenheitTemps.Average != 7 {
+		t.Errorf("Min, max and average %d, %d, %d should be -40, 54 and 7", fahrenheitTemps.Min, fahrenheitTemps.Max, fahrenheitTemps.Average)
+	}
+	if celsiusTemp := fahrenheitTemps.Temps[0].InUnit(Celsius); celsiusTemp.Temp != 12 {
+		t.Errorf("Temp %d should be converted back to 12C", celsiusTemp.Temp)
+	}
+}
+
+func TestDiurnalModelIsWarmerInAfternoonThanAtNight(t *testing.T) {
+	location, _ := time.LoadLocation("Europe/Paris")
+	diurnalProvider := tempProvider.WithModel(DiurnalModel).WithSeed(42)
+	for day := 1; day <= 28; day++ {
+		night, _ := diurnalProvider.generate("PAR", time.Date(2015, 7, day, 3, 0, 0, 0, location))
+		afternoon, _ := diurnalProvider.generate("PAR", time.Date(2015, 7, day, 15, 0, 0, 0, location))
+		if afternoon <= night {
+			t.Errorf("Temp at 15h %d should be warmer than temp at 3h %d on July %d", afternoon, night, day)
+		}
+	}
+}
+
+func TestEmptyRangesDoNotBreakGeneration(t *testing.T) {
+	providerRand := rand.New(rand.NewSource(1))
+	requestTime := time.Date(2015, 4, 16, 13, 00, 00, 00, time.UTC)
+	for _, model := range []GeneratorModel{UniformModel, DiurnalModel} {
+		for i := 0; i < 100; i++ {
+			if temp := model.generate(10, 10, requestTime, providerRand); temp < 9 || temp > 12 {
+				t.Fatalf("Temp of %s model should stay near 10 instead of %d", model, temp)
+			}
+		}
 	}
 }
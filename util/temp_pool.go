@@ -0,0 +1,206 @@
+package util
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"math/rand"
+	"time"
+)
+
+// storageBatchSize is the number of temps provided for a period between two
+// writes of the city file, unless they are streamed
+const storageBatchSize = 50
+
+type batchJob struct {
+	times  []time.Time
+	result chan batchResult
+}
+
+type batchResult struct {
+	temps     []CityTemp
+	generated temps
+	failures  []DateFailure
+}
+
+// getForDates provides temps for batches of times received from timesChan
+// with a pool of workers. Temps are sent to tempsChan in the order of
+// timesChan once at least batchSize of them are provided and the generated
+// ones are stored. Temps generated before ctx
+// is cancelled are still stored
+func (tempProvider TempProvider) getForDates(ctx context.Context, city string, timesChan chan []time.Time, batchSize int, tempsChan chan []CityTemp) (failures []DateFailure) {
+	results := tempProvider.getBatches(ctx, city, timesChan)
+	var held []CityTemp
+	var toStore temps
+	store := func() {
+		if len(toStore) > 0 {
+			if err := tempProvider.saveTemps(city, toStore); err != nil {
+				failures = append(failures, storageFailures(toStore, err)...)
+				held = withoutTimes(held, toStore)
+			}
+		}
+		toStore = nil
+	}
+	defer store()
+	flush := func() bool {
+		store()
+		if len(held) == 0 {
+			return true
+		}
+		select {
+		case tempsChan <- held:
+			held = nil
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+
+	for result := range results {
+		var batch batchResult
+		select {
+		case batch = <-result:
+		case <-ctx.Done():
+			return failures
+		}
+		failures = append(failures, batch.failures...)
+		held = append(held, batch.temps...)
+		toStore = append(toStore, batch.generated...)
+		if len(held) >= batchSize && !flush() {
+			return failures
+		}
+	}
+	if ctx.Err() != nil {
+		return failures
+	}
+	flush()
+	return failures
+}
+
+// getBatches hands batches of times received from timesChan to the workers
+// and returns their results in the order of timesChan
+func (tempProvider TempProvider) getBatches(ctx context.Context, city string, timesChan chan []time.Time) chan chan batchResult {
+	jobs := make(chan batchJob)
+	// Results are queued in the order of batches, at most one per worker
+	results := make(chan chan batchResult, tempProvider.workers)
+	go dispatchBatches(ctx, timesChan, jobs, results)
+	for worker := 0; worker < tempProvider.workers; worker++ {
+		go func() {
+			for job := range jobs {
+				job.result <- tempProvider.getBatch(ctx, city, job.times)
+			}
+		}()
+	}
+	return results
+}
+
+func dispatchBatches(ctx context.Context, timesChan chan []time.Time, jobs chan batchJob, results chan chan batchResult) {
+	defer close(results)
+	defer close(jobs)
+	for {
+		var times []time.Time
+		var open bool
+		select {
+		case times, open = <-timesChan:
+		case <-ctx.Done():
+			return
+		}
+		if !open {
+			return
+		}
+		result := make(chan batchResult, 1)
+		select {
+		case results <- result:
+		case <-ctx.Done():
+			return
+		}
+		select {
+		case jobs <- batchJob{times, result}:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// getBatch provides stored temps or generates them without storing them
+// The cache is locked once for the whole batch and temps are generated
+// without holding the lock
+func (tempProvider TempProvider) getBatch(ctx context.Context, city string, times []time.Time) batchResult {
+	result := batchResult{temps: make([]CityTemp, 0, len(times))}
+	if len(times) == 0 {
+		return result
+	}
+	// Unreadable stored temps are generated again, like with Get
+	stored, _ := tempProvider.getTempsForTimes(city, times)
+	tempSample, sampleErr := tempProvider.getCityData(city, times[0])
+	for _, time := range times {
+		if ctx.Err() != nil {
+			return result
+		}
+		temp, found := stored[time.Unix()]
+		if !found {
+			err := sampleErr
+			if err == nil {
+				temp, err = tempProvider.generateFrom(tempSample, city, time)
+			}
+			if err != nil {
+				result.failures = append(result.failures, DateFailure{TempTime(time), err})
+				continue
+			}
+			result.generated = append(result.generated, Temp{time, temp, false})
+		}
+		result.temps = append(result.temps, NewCityTemp(city, time, temp))
+	}
+	return result
+}
+
+func storageFailures(notStored temps, err error) []DateFailure {
+	failures := make([]DateFailure, 0, len(notStored))
+	for _, temp := range notStored {
+		failures = append(failures, DateFailure{TempTime(temp.Time), err})
+	}
+	return failures
+}
+
+func withoutTimes(cityTemps []CityTemp, removed temps) []CityTemp {
+	removedTimes := make(map[int64]bool, len(removed))
+	for _, temp := range removed {
+		removedTimes[temp.Time.Unix()] = true
+	}
+	kept := make([]CityTemp, 0, len(cityTemps))
+	for _, cityTemp := range cityTemps {
+		if !removedTimes[time.Time(cityTemp.Time).Unix()] {
+			kept = append(kept, cityTemp)
+		}
+	}
+	return kept
+}
+
+// getRand returns a random generator depending only on provider seed, city
+// and time so that generated temps do not depend on goroutines scheduling
+func (tempProvider TempProvider) getRand(city string, requestTime time.Time) *rand.Rand {
+	hash := fnv.New64a()
+	fmt.Fprintf(hash, "%d/%s/%d", tempProvider.seed, city, requestTime.Unix())
+	source := readingSource(hash.Sum64())
+	return rand.New(&source)
+}
+
+// readingSource is a splitmix64 random source, cheap to create for every
+// generated temp
+type readingSource uint64
+
+func (source *readingSource) Uint64() uint64 {
+	*source += 0x9e3779b97f4a7c15
+	z := uint64(*source)
+	z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+	z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+	return z ^ (z >> 31)
+}
+
+func (source *readingSource) Int63() int64 {
+	return int64(source.Uint64() >> 1)
+}
+
+func (source *readingSource) Seed(seed int64) {
+	*source = readingSource(seed)
+}
@@ -0,0 +1,205 @@
+package util
+
+import (
+	"context"
+	"errors"
+	"os"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func newTempProviderInDir(dir string, t testing.TB) TempProvider {
+	cities, err := os.ReadFile("../resources/cities.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = os.WriteFile(dir+"/cities.json", cities, 0644); err != nil {
+		t.Fatal(err)
+	}
+	dataUtils, _ := NewDataUtils(dir + "/cities.json")
+	provider, _ := NewTempProvider(dataUtils)
+	return provider
+}
+
+func getTempsForPeriod(provider TempProvider, duration string, t testing.TB) CityTemps {
+	location, _ := time.LoadLocation("Europe/Paris")
+	timesChan, err := utils.GetDatesForPeriod(context.Background(), time.Date(2015, 4, 16, 10, 0, 0, 0, location), duration)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cityTemps, err := provider.GetForDates(context.Background(), "PAR", timesChan)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cityTemps
+}
+
+func TestGetForDatesDoesNotDependOnWorkers(t *testing.T) {
+	sequentialTemps := getTempsForPeriod(newTempProviderInDir(t.TempDir(), t).WithSeed(1987).WithWorkers(1), "1Y", t)
+	parallelTemps := getTempsForPeriod(newTempProviderInDir(t.TempDir(), t).WithSeed(1987).WithWorkers(8), "1Y", t)
+	if len(sequentialTemps.Temps) != 366 || len(parallelTemps.Temps) != 366 {
+		t.Fatalf("We should get 366 temps, got %d and %d", len(sequentialTemps.Temps), len(parallelTemps.Temps))
+	}
+	for i, sequentialTemp := range sequentialTemps.Temps {
+		parallelTemp := parallelTemps.Temps[i]
+		if !sameCityTemp(parallelTemp, sequentialTemp) {
+			t.Errorf("Temp %v generated in parallel should be equal to %v generated sequentially", parallelTemp, sequentialTemp)
+		}
+		if i > 0 && !time.Time(parallelTemp.Time).After(time.Time(parallelTemps.Temps[i-1].Time)) {
+			t.Errorf("Temp %v should be after %v", parallelTemp, parallelTemps.Temps[i-1])
+		}
+	}
+}
+
+func TestGetForDatesStoresGeneratedTemps(t *testing.T) {
+	dir := t.TempDir()
+	generatedTemps := getTempsForPeriod(newTempProviderInDir(dir, t).WithSeed(1), "2M", t)
+	// Another seed on the same files should only read stored temps
+	storedTemps := getTempsForPeriod(newTempProviderInDir(dir, t).WithSeed(2), "2M", t)
+	for i, generatedTemp := range generatedTemps.Temps {
+		if !sameCityTemp(storedTemps.Temps[i], generatedTemp) {
+			t.Errorf("Stored temp %v should be equal to generated one %v", storedTemps.Temps[i], generatedTemp)
+		}
+	}
+}
+
+func sameCityTemp(cityTemp1, cityTemp2 CityTemp) bool {
+	return cityTemp1.City == cityTemp2.City && cityTemp1.Temp == cityTemp2.Temp &&
+		time.Time(cityTemp1.Time).Equal(time.Time(cityTemp2.Time))
+}
+
+func benchmarkGetForDates(workers int, b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		provider := newTempProviderInDir(b.TempDir(), b).WithWorkers(workers)
+		timesChan := resolvedDates("3Y", b)
+		b.StartTimer()
+		if _, err := provider.GetForDates(context.Background(), "PAR", timesChan); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// benchmarkGetBatches times the workers only, without storing generated
+// temps. A gain of several workers needs several CPUs, like with
+// go test -bench GetBatches -cpu 4
+func benchmarkGetBatches(workers int, b *testing.B) {
+	provider := newTempProviderInDir(b.TempDir(), b).WithWorkers(workers)
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		timesChan := resolvedDates("10Y", b)
+		b.StartTimer()
+		for result := range provider.getBatches(context.Background(), "PAR", timesChan) {
+			if batch := <-result; len(batch.failures) > 0 {
+				b.Fatal(batch.failures)
+			}
+		}
+	}
+}
+
+// resolvedDates returns a channel of dates of the period already resolved,
+// not to time their resolution
+func resolvedDates(duration string, b *testing.B) chan []time.Time {
+	location, _ := time.LoadLocation("Europe/Paris")
+	datesChan, _ := utils.GetDatesForPeriod(context.Background(), time.Date(2015, 4, 16, 10, 0, 0, 0, location), duration)
+	var batches [][]time.Time
+	for times := range datesChan {
+		batches = append(batches, times)
+	}
+	timesChan := make(chan []time.Time, len(batches))
+	for _, times := range batches {
+		timesChan <- times
+	}
+	close(timesChan)
+	return timesChan
+}
+
+// BenchmarkGetOneByOne provides temps of the period storing each of them as
+// before worker pool
+func BenchmarkGetOneByOne(b *testing.B) {
+	location, _ := time.LoadLocation("Europe/Paris")
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		provider := newTempProviderInDir(b.TempDir(), b)
+		timesChan, _ := utils.GetDatesForPeriod(context.Background(), time.Date(2015, 4, 16, 10, 0, 0, 0, location), "3Y")
+		b.StartTimer()
+		for times := range timesChan {
+			for _, time := range times {
+				if _, err := provider.Get("PAR", time); err != nil {
+					b.Fatal(err)
+				}
+			}
+		}
+	}
+}
+
+func BenchmarkGetForDatesWithOneWorker(b *testing.B) {
+	benchmarkGetForDates(1, b)
+}
+
+func BenchmarkGetForDatesWithFourWorkers(b *testing.B) {
+	benchmarkGetForDates(4, b)
+}
+
+func BenchmarkGetBatchesWithOneWorker(b *testing.B) {
+	benchmarkGetBatches(1, b)
+}
+
+func BenchmarkGetBatchesWithFourWorkers(b *testing.B) {
+	benchmarkGetBatches(4, b)
+}
+
+func TestStreamForDatesHandsTempsByBatches(t *testing.T) {
+	provider := newTempProviderInDir(t.TempDir(), t).WithSeed(1987)
+	location, _ := time.LoadLocation("Europe/Paris")
+	timesChan, _ := utils.GetDatesForPeriod(context.Background(), time.Date(2015, 4, 16, 10, 0, 0, 0, location), "1Y")
+	nbBatches, nbTemps, maxBatch := 0, 0, 0
+	stats, err := provider.StreamForDates(context.Background(), "PAR", timesChan, func(temps []CityTemp) error {
+		nbBatches++
+		nbTemps += len(temps)
+		if len(temps) > maxBatch {
+			maxBatch = len(temps)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Error(err)
+	}
+	if nbTemps != 366 || nbBatches < 2 {
+		t.Errorf("366 temps should be streamed in several batches, got %d in %d batches", nbTemps, nbBatches)
+	}
+	// Temps are handed as soon as a batch of dates is provided
+	if maxBatch > 5 {
+		t.Errorf("Temps should not wait for the next batches of dates, got a batch of %d", maxBatch)
+	}
+	if stats.Temps != nil || stats.Min > stats.Average || stats.Average > stats.Max {
+		t.Errorf("Only consistent statistics should be returned %v", stats)
+	}
+}
+
+func TestStreamForDatesStopsWhenConsumeFails(t *testing.T) {
+	provider := newTempProviderInDir(t.TempDir(), t)
+	location, _ := time.LoadLocation("Europe/Paris")
+	nbGoroutines := runtime.NumGoroutine()
+	timesChan, _ := utils.GetDatesForPeriod(context.Background(), time.Date(2015, 4, 16, 10, 0, 0, 0, location), "10Y")
+	consumeErr := errors.New("client gone")
+	nbBatches := 0
+	_, err := provider.StreamForDates(context.Background(), "PAR", timesChan, func(temps []CityTemp) error {
+		nbBatches++
+		return consumeErr
+	})
+	if err != consumeErr {
+		t.Errorf("Error %v should be the consume one", err)
+	}
+	if nbBatches != 1 {
+		t.Errorf("Consume should not be called anymore once it failed, called %d times", nbBatches)
+	}
+	// Stopped goroutines may need a moment to exit
+	for wait := 0; wait < 100 && runtime.NumGoroutine() > nbGoroutines; wait++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if runtime.NumGoroutine() > nbGoroutines {
+		t.Errorf("Dates producer and workers should stop, %d goroutines left of %d", runtime.NumGoroutine(), nbGoroutines)
+	}
+}
@@ -1,9 +1,11 @@
 package util
 
 import (
+	"context"
 	"fmt"
 	"regexp"
 	"strconv"
+	"strings"
 	"time"
 )
 
@@ -11,6 +13,8 @@ import (
 type TimeUtils struct {
 	// INHERITANCE BY COMPOSITION
 	DataUtils
+	now       func() time.Time
+	dstPolicy DSTPolicy
 }
 
 type duration struct {
@@ -20,9 +24,28 @@ type duration struct {
 // TimeFormat is the default time format
 const TimeFormat = time.RFC3339
 
+// LocalTimeFormat is the format for times without time zone, they are
+// considered in the city time zone
+const LocalTimeFormat = "2006-01-02T15:04:05"
+
+var timeLayoutParts = map[string]string{
+	"2006":   "year",
+	"01":     "month",
+	"02":     "day",
+	"15":     "hour",
+	"04":     "minute",
+	"05":     "second",
+	"Z07:00": "time zone offset",
+	"-":      "date separator",
+	"T":      "date and time separator",
+	":":      "time separator",
+}
+
+// EpochLocation is a pseudo location in which times are rendered as Unix
+// seconds
+var EpochLocation = time.FixedZone("epoch", 0)
+
 var timeUtilsNil = TimeUtils{}
-var withoutTimeZoneRegexp *regexp.Regexp
-var withTimeZoneRegexp *regexp.Regexp
 var durationRegexp *regexp.Regexp
 var durationLengthRegexp *regexp.Regexp
 var durationUnitRegexp *regexp.Regexp
@@ -31,14 +54,6 @@ var timeNil = time.Time{}
 // NewTimeUtils is the constructor for TimeUtils
 func NewTimeUtils(dataUtils DataUtils) (TimeUtils, error) {
 	var error error
-	withoutTimeZoneRegexp, error = regexp.Compile("\\d{4}-\\d{2}-\\d{2}T\\d{2}\\:\\d{2}\\:\\d{2}")
-	if error != nil {
-		return timeUtilsNil, error
-	}
-	withTimeZoneRegexp, error = regexp.Compile("\\d{4}-\\d{2}-\\d{2}T\\d{2}\\:\\d{2}\\:\\d{2}Z|\\+|\\-\\d{2}\\:\\d{2}")
-	if error != nil {
-		return timeUtilsNil, error
-	}
 	durationRegexp, error = regexp.Compile("(\\d+[YMD])?(\\d+[MD])?(\\d+D)?")
 	if error != nil {
 		return timeUtilsNil, error
@@ -51,65 +66,152 @@ func NewTimeUtils(dataUtils DataUtils) (TimeUtils, error) {
 	if error != nil {
 		return timeUtilsNil, error
 	}
-	return TimeUtils{dataUtils}, nil
+	error = compileRelativeTimeRegexps()
+	if error != nil {
+		return timeUtilsNil, error
+	}
+	return TimeUtils{dataUtils, time.Now, DSTEarliest}, nil
+}
+
+// WithDSTPolicy returns TimeUtils resolving local times which do not exist
+// or exist twice because of daylight saving time with the provided policy
+func (utils TimeUtils) WithDSTPolicy(policy DSTPolicy) TimeUtils {
+	utils.dstPolicy = policy
+	return utils
 }
 
 // GetTime provides the time (with or without timezone) for a given city
-// formattedTime ex :
+// formattedTime ex : 2015-04-02T17:00:00 in the city time zone, any RFC 3339
+// time like 2015-04-02T17:00:00.5-04:00 converted to the city time zone or
+// relative expressions evaluated in the city time zone like now, today 15h,
+// yesterday, -3d, last monday 08:00 or 2015-04-02
 func (utils TimeUtils) GetTime(formattedTime, cityStr string) (time.Time, error) {
-	hasNoTimeZone, timeFormatError := hasNoTimeZone(formattedTime)
-	if timeFormatError != nil {
-		return timeNil, timeFormatError
+	location, error := utils.GetLocation(cityStr)
+	if error != nil {
+		return timeNil, error
 	}
 
-	if hasNoTimeZone {
-		formattedTime += "Z"
+	relativeTime, isRelative, error := getRelativeTime(strings.TrimSpace(formattedTime), utils.now(), location, utils.dstPolicy)
+	if isRelative {
+		if error != nil {
+			return timeNil, asInvalidArgument("date", error)
+		}
+		return getTimeWithoutMinuteSecondNano(relativeTime), nil
 	}
-	location, error := utils.getLocation(cityStr)
+
+	completeTime, error := parseAbsoluteTime(formattedTime, location, utils.dstPolicy)
 	if error != nil {
-		return timeNil, error
+		return timeNil, asInvalidArgument("date", error)
 	}
-	completeTime, error := time.ParseInLocation(TimeFormat, formattedTime, location)
-	if error != nil {
-		return timeNil, error
+	return getTimeWithoutMinuteSecondNano(completeTime), nil
+}
+
+// parseAbsoluteTime parses times like 2015-04-02T17:00:00 in the provided
+// location resolved with the DST policy or any RFC 3339 time like
+// 2015-04-02T17:00:00.5-04:00 converted to the provided location
+func parseAbsoluteTime(formattedTime string, location *time.Location, policy DSTPolicy) (time.Time, error) {
+	formattedTime = normalizeRFC3339Case(strings.TrimSpace(formattedTime))
+	wallClock, localError := time.ParseInLocation(LocalTimeFormat, formattedTime, time.UTC)
+	if localError == nil {
+		return policy.resolve(wallClock, location)
+	}
+	offsetTime, offsetError := time.Parse(time.RFC3339, formattedTime)
+	if offsetError == nil {
+		return offsetTime.In(location), nil
+	}
+	// Only trailing text after seconds can be a time zone offset
+	if parseError, isParseError := localError.(*time.ParseError); isParseError && !strings.HasPrefix(parseError.Message, ": extra text") {
+		return timeNil, describeTimeParseError(formattedTime, localError)
+	}
+	return timeNil, describeTimeParseError(formattedTime, offsetError)
+}
+
+// normalizeRFC3339Case upper cases date and time separator and UTC designator
+// as RFC 3339 allows them in lower case
+func normalizeRFC3339Case(formattedTime string) string {
+	if len(formattedTime) > 10 && formattedTime[10] == 't' {
+		formattedTime = formattedTime[:10] + "T" + formattedTime[11:]
+	}
+	if strings.HasSuffix(formattedTime, "z") {
+		formattedTime = strings.TrimSuffix(formattedTime, "z") + "Z"
+	}
+	return formattedTime
+}
+
+func describeTimeParseError(formattedTime string, err error) error {
+	expected := fmt.Sprintf("Provided time should be like %s, %s or relative like %s", LocalTimeFormat, TimeFormat, RelativeTimeExamples)
+	parseError, isParseError := err.(*time.ParseError)
+	if !isParseError {
+		return fmt.Errorf("Invalid time '%s': %s. %s", formattedTime, err, expected)
+	}
+	detail := strings.TrimPrefix(parseError.Message, ": ")
+	part, isKnownPart := timeLayoutParts[parseError.LayoutElem]
+	switch {
+	case isKnownPart && detail != "":
+		return fmt.Errorf("Invalid %s in time '%s': %s. %s", part, formattedTime, detail, expected)
+	case isKnownPart && parseError.ValueElem == "":
+		return fmt.Errorf("Missing %s in time '%s'. %s", part, formattedTime, expected)
+	case isKnownPart:
+		return fmt.Errorf("Invalid %s at '%s' in time '%s'. %s", part, parseError.ValueElem, formattedTime, expected)
+	default:
+		return fmt.Errorf("Invalid time '%s': %s. %s", formattedTime, detail, expected)
 	}
-	return getTimeWithoutMinuteSecondNano(completeTime, location), nil
 }
 
 // GetDatesForPeriod return a channel receiving by blocks day by day dates from startTime to
 // end of period. Expecting duration like 1Y3M2D, 1Y2M, 3M2D or 3D
-func (utils TimeUtils) GetDatesForPeriod(endTime time.Time, duration string) (chan []time.Time, error) {
+// The channel is closed early when ctx is cancelled or its deadline exceeded
+func (utils TimeUtils) GetDatesForPeriod(ctx context.Context, endTime time.Time, duration string) (chan []time.Time, error) {
 	startTime, err := utils.getStartTime(endTime, duration)
 	if err != nil {
 		return nil, err
 	}
 
 	datesChan := make(chan []time.Time)
-	go utils.generateDates(startTime, endTime, datesChan)
+	go utils.generateDates(ctx, startTime, endTime, datesChan)
 
 	return datesChan, nil
 }
 
-func (utils TimeUtils) generateDates(startTime, endTime time.Time, datesChan chan []time.Time) {
+// generateDates sends the same local time every day from startTime to
+// endTime. Local times rejected by the DST policy are skipped
+func (utils TimeUtils) generateDates(ctx context.Context, startTime, endTime time.Time, datesChan chan []time.Time) {
 	count := 0
 	empty := true
 	generatedDates := make([]time.Time, 0, 5)
-	generatedDate := startTime
+	location := endTime.Location()
+	wallClock, endWallClock := getWallClock(startTime.In(location)), getWallClock(endTime)
+
+	// An abandoned consumer must not block generation forever
+	send := func(dates []time.Time) bool {
+		select {
+		case datesChan <- dates:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
 
 	defer func() {
 		if !empty {
-			datesChan <- generatedDates
+			send(generatedDates)
 		}
 		close(datesChan)
 	}()
 
-	for generatedDate.Before(endTime) || generatedDate.Equal(endTime) {
+	for ; !wallClock.After(endWallClock); wallClock = wallClock.AddDate(0, 0, 1) {
+		generatedDate, err := utils.dstPolicy.resolve(wallClock, location)
+		if err != nil {
+			continue
+		}
 		generatedDates = append(generatedDates, generatedDate)
-		generatedDate = generatedDate.AddDate(0, 0, 1)
 		count++
 		empty = false
 		if count%5 == 0 {
-			datesChan <- generatedDates
+			if !send(generatedDates) {
+				empty = true
+				return
+			}
 			generatedDates = make([]time.Time, 0, 5)
 			empty = true
 		}
@@ -118,7 +220,7 @@ func (utils TimeUtils) generateDates(startTime, endTime time.Time, datesChan cha
 
 func (utils TimeUtils) getStartTime(endTime time.Time, durationString string) (time.Time, error) {
 	if !isFoundOnce(durationRegexp, durationString) {
-		return timeNil, fmt.Errorf("%s has not the right duration format. Expecting duration like 1Y3M2D, 1Y2M, 3M2D or 3D", durationString)
+		return timeNil, InvalidArgumentErrorf("duration", "%s has not the right duration format. Expecting duration like 1Y3M2D, 1Y2M, 3M2D or 3D", durationString)
 	}
 	duration := duration{}
 	durations := durationRegexp.FindAllStringSubmatch(durationString, -1)[0][1:]
@@ -140,7 +242,13 @@ func (utils TimeUtils) getStartTime(endTime time.Time, durationString string) (t
 		}
 	}
 
-	return endTime.AddDate(-duration.year, -duration.month, -duration.day), nil
+	startWallClock := getWallClock(endTime).AddDate(-duration.year, -duration.month, -duration.day)
+	policy := utils.dstPolicy
+	if policy == DSTReject {
+		// Start time is only a bound of the period, rejected dates are skipped later
+		policy = DSTEarliest
+	}
+	return policy.resolve(startWallClock, endTime.Location())
 }
 
 func parseToInt(numString string) (int, error) {
@@ -148,40 +256,43 @@ func parseToInt(numString string) (int, error) {
 	return int(num), err
 }
 
-func hasNoTimeZone(formattedTime string) (bool, error) {
-	if isFoundOnce(withTimeZoneRegexp, formattedTime) {
-		return false, nil
-	}
-	if isFoundOnce(withoutTimeZoneRegexp, formattedTime) {
-		return true, nil
-	}
-	withoutTimeExample := withoutTimeZoneRegexp.FindString(time.RFC3339)
-	return false, fmt.Errorf("Provided time should be like %s or %s", withoutTimeExample, TimeFormat)
-}
-
 func isFoundOnce(curRegexp *regexp.Regexp, stringToMatch string) bool {
 	return nbOfHits(curRegexp, stringToMatch) == 1
 }
 
-func isFoundAtLeastOnce(curRegexp *regexp.Regexp, stringToMatch string) bool {
-	return nbOfHits(curRegexp, stringToMatch) >= 1
-}
-
 func nbOfHits(curRegexp *regexp.Regexp, stringToMatch string) int {
 	return len(curRegexp.FindAllIndex([]byte(stringToMatch), -1))
 }
 
 // GetTimeWithoutMinuteSecondNano returns the time you provide without minutes, seconds or nanos
 func (TimeUtils) GetTimeWithoutMinuteSecondNano(input time.Time) time.Time {
-	return getTimeWithoutMinuteSecondNano(input, input.Location())
+	return getTimeWithoutMinuteSecondNano(input)
+}
+
+// getTimeWithoutMinuteSecondNano keeps the UTC offset of input so that an
+// hour which exists twice because of daylight saving time is not changed
+func getTimeWithoutMinuteSecondNano(input time.Time) time.Time {
+	sinceHour := time.Duration(input.Minute())*time.Minute + time.Duration(input.Second())*time.Second +
+		time.Duration(input.Nanosecond())
+	return input.Add(-sinceHour)
 }
 
-func getTimeWithoutMinuteSecondNano(input time.Time, location *time.Location) time.Time {
-	input = time.Date(input.Year(), input.Month(), input.Day(), input.Hour(), 0, 0, 0, location)
-	return input
+// LoadDisplayLocation returns the location in which times are rendered
+// name is an IANA time zone like Europe/Paris, UTC, Local or epoch for
+// Unix seconds
+func LoadDisplayLocation(name string) (*time.Location, error) {
+	if strings.EqualFold(name, "epoch") || strings.EqualFold(name, "unix") {
+		return EpochLocation, nil
+	}
+	location, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, InvalidArgumentErrorf("tz", "Unknown time zone '%s'. Expecting an IANA time zone like Europe/Paris, UTC or epoch", name)
+	}
+	return location, nil
 }
 
-func (utils TimeUtils) getLocation(cityStr string) (*time.Location, error) {
+// GetLocation returns the time zone of the city named cityStr
+func (utils TimeUtils) GetLocation(cityStr string) (*time.Location, error) {
 	ianaTimezone, error := utils.getIANATimezone(cityStr)
 	if error != nil {
 		return nil, error
@@ -201,5 +312,5 @@ func (utils TimeUtils) getIANATimezone(cityStr string) (string, error) {
 		}
 	}
 
-	return "", fmt.Errorf("No data for city '%s'.", cityStr)
+	return "", NotFoundErrorf("city", "No data for city '%s'.", cityStr)
 }
@@ -1,6 +1,8 @@
 package util
 
 import (
+	"context"
+	"strings"
 	"testing"
 	"time"
 )
@@ -44,13 +46,13 @@ func TestGetTimeShouldReturnErrorIfFileDoesNotExist(t *testing.T) {
 	assertError(execution, "Should have an error when creating component", t)
 }
 
-func TestWithDefaultFormattedDate(t *testing.T) {
-	formattedTime := time.Date(2015, 4, 8, 16, 0, 0, 0, time.Local).Format(TimeFormat)
+func TestWithOffsetIsConvertedToCityTime(t *testing.T) {
+	formattedTime := time.Date(2015, 4, 8, 16, 0, 0, 0, time.FixedZone("", 2*60*60)).Format(TimeFormat)
 	dakarTime, error := utils.GetTime(formattedTime, "DKR")
 	if error != nil {
 		t.Errorf("Should not have an error '%s'", error)
 	}
-	expectedFormattedDakarTime := "2015-04-08T16:00:00Z"
+	expectedFormattedDakarTime := "2015-04-08T14:00:00Z"
 	actualDakarTime := dakarTime.Format(TimeFormat)
 	if actualDakarTime != expectedFormattedDakarTime {
 		t.Errorf("Expected time '%s' is different from the actual one '%s'", expectedFormattedDakarTime,
@@ -58,6 +60,53 @@ func TestWithDefaultFormattedDate(t *testing.T) {
 	}
 }
 
+func TestWithRFC3339TimesShouldBeConvertedToCityTime(t *testing.T) {
+	expectedTimes := map[string]string{
+		"2015-04-02T17:00:00Z":           "2015-04-02T19:00:00+02:00",
+		"2015-04-02t17:00:00z":           "2015-04-02T19:00:00+02:00",
+		"2015-04-02T17:00:00-04:00":      "2015-04-02T23:00:00+02:00",
+		"2015-04-02T17:00:00.123+02:00":  "2015-04-02T17:00:00+02:00",
+		"2015-04-02T23:30:00.5-04:00":    "2015-04-03T05:00:00+02:00",
+		"2015-04-02T17:59:59.999999999Z": "2015-04-02T19:00:00+02:00",
+		"2015-04-02T17:00:00.25":         "2015-04-02T17:00:00+02:00",
+	}
+	for formattedTime, expectedParisTime := range expectedTimes {
+		parisTime, error := utils.GetTime(formattedTime, "PAR")
+		if error != nil {
+			t.Errorf("Should not have an error for '%s' '%s'", formattedTime, error)
+			continue
+		}
+		actualParisTime := parisTime.Format(TimeFormat)
+		if actualParisTime != expectedParisTime {
+			t.Errorf("Expected time '%s' for '%s' is different from the actual one '%s'", expectedParisTime,
+				formattedTime, actualParisTime)
+		}
+	}
+}
+
+func TestWithInvalidTimePartShouldReportIt(t *testing.T) {
+	expectedParts := map[string]string{
+		"2015-13-02T17:00:00":       "Invalid month",
+		"2015-04-31T17:00:00":       "day out of range",
+		"2015-04-02T25:00:00":       "Invalid hour",
+		"2015-04-02X17:00:00":       "Invalid date and time separator at 'X17:00:00'",
+		"2015-04-02T17:00":          "Missing time separator",
+		"2015-04-02T17:00:00+25:00": "Invalid time zone offset",
+		"2015-04-02T17:00:00+0200":  "Invalid time zone offset at '+0200'",
+		"2015-04-02T17:00:00+":      "Invalid time zone offset",
+	}
+	for formattedTime, expectedPart := range expectedParts {
+		_, error := utils.GetTime(formattedTime, "PAR")
+		if error == nil {
+			t.Errorf("Should have an error for '%s'", formattedTime)
+			continue
+		}
+		if !strings.Contains(error.Error(), expectedPart) {
+			t.Errorf("Error '%s' for '%s' should contain '%s'", error, formattedTime, expectedPart)
+		}
+	}
+}
+
 func TestWithBadDateFormatShouldFail(t *testing.T) {
 	execution := func() (interface{}, error) { return utils.GetTime("nimportequoi", "DKR") }
 	assertError(execution, "Should have an error when getting 'nimportequoi' time", t)
@@ -100,7 +149,7 @@ func TestGetEndTimeWithWrongDuration(t *testing.T) {
 }
 
 func TestGetDatesFor3DaysPeriodAtBeginningOfMonth(t *testing.T) {
-	datesChan, err := utils.GetDatesForPeriod(time.Date(2015, 3, 2, 16, 0, 0, 0, time.Local), "3D")
+	datesChan, err := utils.GetDatesForPeriod(context.Background(), time.Date(2015, 3, 2, 16, 0, 0, 0, time.Local), "3D")
 	if err != nil {
 		t.Errorf("Should not have an error '%s'", err)
 	}
@@ -122,7 +171,7 @@ func TestGetDatesFor3DaysPeriodAtBeginningOfMonth(t *testing.T) {
 }
 
 func TestGetDatesFor5DaysPeriod(t *testing.T) {
-	datesChan, err := utils.GetDatesForPeriod(time.Date(2015, 3, 12, 16, 0, 0, 0, time.Local), "5D")
+	datesChan, err := utils.GetDatesForPeriod(context.Background(), time.Date(2015, 3, 12, 16, 0, 0, 0, time.Local), "5D")
 	if err != nil {
 		t.Errorf("Should not have an error '%s'", err)
 	}
@@ -179,3 +228,26 @@ external:
 	}
 	return containsAll, missingElements
 }
+
+func TestGetDatesForPeriodStopsWhenContextIsCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	datesChan, err := utils.GetDatesForPeriod(ctx, time.Date(2015, 3, 12, 16, 0, 0, 0, time.Local), "10Y")
+	if err != nil {
+		t.Errorf("Should not have an error '%s'", err)
+	}
+	<-datesChan
+	cancel()
+	// Abandoned channel should be closed instead of blocking generation
+	timeout := time.After(time.Second)
+	for {
+		select {
+		case _, open := <-datesChan:
+			if !open {
+				return
+			}
+		case <-timeout:
+			t.Errorf("Dates channel should be closed once context is cancelled")
+			return
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package util
+
+import (
+	"encoding/json"
+	"math"
+	"strings"
+)
+
+// TempUnit is the unit in which temps are rendered. Temps are generated and
+// stored in Celsius
+type TempUnit int
+
+const (
+	// Celsius degrees, the unit of generated temps
+	Celsius TempUnit = iota
+	// Fahrenheit degrees
+	Fahrenheit
+)
+
+var tempUnitNames = []string{"celsius", "fahrenheit"}
+var tempUnitSymbols = []string{"C", "F"}
+
+// TempUnits lists the names of available units
+var TempUnits = strings.Join(tempUnitNames, ", ")
+
+// ParseTempUnit returns the unit named celsius or fahrenheit, or with its
+// symbol C or F
+func ParseTempUnit(name string) (TempUnit, error) {
+	for unit, unitName := range tempUnitNames {
+		if strings.EqualFold(unitName, name) || strings.EqualFold(tempUnitSymbols[unit], name) {
+			return TempUnit(unit), nil
+		}
+	}
+	return Celsius, InvalidArgumentErrorf("units", "Unknown unit '%s'. Expecting one of %s", name, TempUnits)
+}
+
+func (unit TempUnit) String() string {
+	return tempUnitNames[unit]
+}
+
+// Symbol returns C or F
+func (unit TempUnit) Symbol() string {
+	return tempUnitSymbols[unit]
+}
+
+// MarshalJSON renders the unit symbol
+func (unit TempUnit) MarshalJSON() ([]byte, error) {
+	return json.Marshal(unit.Symbol())
+}
+
+// convert returns temp in unit rounded to the closest degree
+func (unit TempUnit) convert(temp float64, from TempUnit) int {
+	if unit == from {
+		return int(math.Floor(temp + 0.5))
+	}
+	if unit == Fahrenheit {
+		return int(math.Floor(temp*9/5 + 32 + 0.5))
+	}
+	return int(math.Floor((temp-32)*5/9 + 0.5))
+}
+
+// InUnit returns the temp converted to unit
+func (obj CityTemp) InUnit(unit TempUnit) CityTemp {
+	obj.Temp = unit.convert(float64(obj.Temp), obj.Unit)
+	obj.Unit = unit
+	return obj
+}
+
+// InUnit returns the temps and their statistics converted to unit
+func (obj CityTemps) InUnit(unit TempUnit) CityTemps {
+	from := Celsius
+	if len(obj.Temps) > 0 {
+		from = obj.Temps[0].Unit
+	}
+	temps := make([]CityTemp, 0, len(obj.Temps))
+	for _, temp := range obj.Temps {
+		temps = append(temps, temp.InUnit(unit))
+	}
+	obj.Temps = temps
+	obj.Min, obj.Max = unit.convert(float64(obj.Min), from), unit.convert(float64(obj.Max), from)
+	obj.Average = unit.convert(float64(obj.Average), from)
+	return obj
+}
@@ -0,0 +1,68 @@
+package util
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Validate checks cities definitions and temps stored for them and returns
+// every problem found
+func (utils DataUtils) Validate() []error {
+	citiesData, err := utils.getCitiesData()
+	if err != nil {
+		return []error{err}
+	}
+	var problems []error
+	codes, names := make(map[string]bool), make(map[string]bool)
+	for index, cityData := range citiesData {
+		if cityData.Code == "" || cityData.Name == "" {
+			problems = append(problems, fmt.Errorf("City #%d needs a name and an IATA code", index+1))
+			continue
+		}
+		if codes[strings.ToUpper(cityData.Code)] || names[strings.ToUpper(cityData.Name)] {
+			problems = append(problems, fmt.Errorf("City %s is defined more than once", cityData.Name))
+		}
+		codes[strings.ToUpper(cityData.Code)], names[strings.ToUpper(cityData.Name)] = true, true
+		problems = append(problems, cityData.validate()...)
+
+		cityFile, err := utils.getResourceFileName(cityData.Code + ".json")
+		if err == nil {
+			utils.cache.lock.Lock()
+			_, err = utils.loadTemps(cityFile)
+			utils.cache.lock.Unlock()
+		}
+		if err != nil {
+			problems = append(problems, fmt.Errorf("City %s: %w", cityData.Name, err))
+		}
+	}
+	return problems
+}
+
+// validate checks the city has a known time zone and a valid sample for
+// every sample date of a year
+func (cityData cityData) validate() []error {
+	location, err := time.LoadLocation(cityData.IanaTZ)
+	if err != nil {
+		return []error{fmt.Errorf("City %s has an unknown time zone '%s'", cityData.Name, cityData.IanaTZ)}
+	}
+	var problems []error
+	for _, sample := range cityData.Samples {
+		if len(sample.TempRange) != 2 || sample.TempRange[0] >= sample.TempRange[1] {
+			problems = append(problems, fmt.Errorf("City %s sample of %s should have a range like [min, max] with min below max instead of %v",
+				cityData.Name, sample.Time.Format(TimeFormat), sample.TempRange))
+		}
+	}
+	for month := time.January; month <= time.December; month++ {
+		for _, day := range sampleDays {
+			if month == time.February && day == 30 {
+				continue
+			}
+			sampleTime := time.Date(2014, month, day, 11, 0, 0, 0, location)
+			if _, err = getCityTempSample(cityData, sampleTime); err != nil {
+				problems = append(problems, fmt.Errorf("City %s has no sample for %s", cityData.Name, sampleTime.Format(TimeFormat)))
+			}
+		}
+	}
+	return problems
+}